@@ -0,0 +1,136 @@
+// Command ytfixtures records responses of the YouTube InnerTube API and the parsed items as fixtures
+// for the tests of ytapi. It requires network access.
+//
+//	go run ./cmd/ytfixtures refresh
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"ytbot/config"
+	"ytbot/ytapi"
+)
+
+const upstreamUrl = "https://www.youtube.com"
+
+// fixtureCase is a single call of ytapi whose responses are recorded
+type fixtureCase struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Input string `json:"input"`
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "refresh" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dir := flags.String("dir", filepath.Join("ytapi", "testdata"), "directory of the fixtures")
+	_ = flags.Parse(os.Args[2:])
+
+	cases, err := loadCases(*dir)
+	if err != nil {
+		fail("Failed to load fixture cases:", err)
+	}
+
+	// Playlists are always loaded completely, so that the golden files do not depend on the configuration
+	config.Set(config.KeyPlaylistLimit, "0")
+
+	err = refresh(*dir, cases)
+	if err != nil {
+		fail(err)
+	}
+}
+
+func refresh(dir string, cases []fixtureCase) error {
+	for _, fixture := range cases {
+		server := newFixtureServer(upstreamUrl)
+		items, err := runCase(server, fixture)
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", fixture.Name, err)
+		}
+
+		err = writeJson(filepath.Join(dir, fixture.Name+".responses.json"), server.responses)
+		if err != nil {
+			return err
+		}
+		err = writeJson(filepath.Join(dir, fixture.Name+".golden.json"), items)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Recorded %s: %d items\n", fixture.Name, len(items))
+	}
+	return nil
+}
+
+// runCase performs the call of a fixture case against the fixture server
+func runCase(fixtures *fixtureServer, fixture fixtureCase) ([]ytapi.MediaItem, error) {
+	server := httptest.NewServer(fixtures)
+	defer server.Close()
+	config.Set(config.KeyYouTubeBaseUrl, server.URL)
+
+	switch fixture.Kind {
+	case "search":
+		return ytapi.Search(fixture.Input)
+	case "playlist":
+		return ytapi.GetPlaylistItems(fixture.Input, nil)
+	case "mix":
+		return ytapi.GetMixItems(fixture.Input, "")
+	case "related":
+		return ytapi.GetRelatedVideos(fixture.Input)
+	case "video":
+		item, err := ytapi.GetVideo(fixture.Input)
+		if err != nil {
+			return nil, err
+		}
+		return []ytapi.MediaItem{item}, nil
+	default:
+		return nil, fmt.Errorf("unknown fixture kind `%s`", fixture.Kind)
+	}
+}
+
+func loadCases(dir string) ([]fixtureCase, error) {
+	cases := make([]fixtureCase, 0)
+	err := readJson(filepath.Join(dir, "cases.json"), &cases)
+	return cases, err
+}
+
+func readJson(path string, value interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func writeJson(path string, value interface{}) error {
+	data, err := marshalJson(value)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func marshalJson(value interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes.TrimSpace(data), '\n'), nil
+}
+
+func usage() {
+	fmt.Println("Usage: ytfixtures refresh [-dir <fixtures>]")
+	os.Exit(2)
+}
+
+func fail(args ...interface{}) {
+	fmt.Println(args...)
+	os.Exit(1)
+}
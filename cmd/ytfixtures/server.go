@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/buger/jsonparser"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+)
+
+// requestKeyFields are the fields of InnerTube requests that identify the response
+var requestKeyFields = []string{"query", "browseId", "continuation", "videoId"}
+
+// fixtureServer forwards InnerTube requests to the upstream URL, and records the responses
+type fixtureServer struct {
+	upstream  string
+	responses map[string]json.RawMessage
+	mutex     sync.Mutex
+}
+
+func newFixtureServer(upstream string) *fixtureServer {
+	return &fixtureServer{
+		upstream:  upstream,
+		responses: make(map[string]json.RawMessage),
+	}
+}
+
+func (server *fixtureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := requestKey(r.URL.Path, body)
+
+	req, err := http.NewRequest(r.Method, server.upstream+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+	// Let the transport negotiate the encoding, so that the recorded responses are plain JSON
+	req.Header.Del("Accept-Encoding")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		var compacted bytes.Buffer
+		if json.Compact(&compacted, response) == nil {
+			server.mutex.Lock()
+			server.responses[key] = compacted.Bytes()
+			server.mutex.Unlock()
+		}
+	} else {
+		fmt.Printf("Upstream returned HTTP status %d for %s\n", resp.StatusCode, key)
+	}
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(response)
+}
+
+// requestKey identifies a request by its endpoint and its main parameter, like `search:<query>`.
+// The tests of ytapi look up the recorded responses by the same key.
+func requestKey(urlPath string, body []byte) string {
+	endpoint := path.Base(urlPath)
+	for _, field := range requestKeyFields {
+		if value, err := jsonparser.GetString(body, field); err == nil {
+			return endpoint + ":" + value
+		}
+	}
+	return endpoint
+}
@@ -1,17 +1,57 @@
 package codec
 
 import (
-	"github.com/pion/webrtc/v3/pkg/media/oggreader"
-	"go.uber.org/zap"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"time"
 	"ytbot/config"
 )
 
+// EndReason describes why an Encoder stopped playing
+type EndReason int
+
+const (
+	EndFinished EndReason = iota
+	EndStopped
+	EndFailed
+)
+
+// Encoder decodes a single media item to PCM and plays it through a Mixer
 type Encoder struct {
-	ffmpeg   Ffmpeg
-	sink     AudioSink
-	stopChan chan interface{}
+	ffmpeg    Ffmpeg
+	source    Source
+	mixer     *Mixer
+	input     *MixerInput
+	endOnce   sync.Once
+	ended     chan interface{}
+	endReason EndReason
+	// speaking is set for overlays that signalled speaking to the AudioSink, because nothing else was playing
+	speaking bool
+	err      error
+	errMutex sync.Mutex
+}
+
+// seekingHttpConfig makes ffmpeg reconnect to remote sources that it reads itself, and gives up on stalled connections
+const seekingHttpConfig = "-reconnect 1 -reconnect_delay_max 5 -rw_timeout 15000000"
+
+// Source describes the media that an Encoder should play, from StartOffset until EndOffset if it is set.
+// Remote sources are read through a RangeReader, which uses Resolve to obtain a new URL if the current one expires.
+// Remote sources that start at an offset are read by ffmpeg, which seeks within them using range requests.
+// Endless internet radio streams are marked using Stream, and report their title to OnStreamTitle.
+// HLS playlists are marked using Hls, and are read by ffmpeg itself, starting at the live edge of live streams.
+// Overlays, such as sound effects, duck all other audio and do not notify the AudioSink about their playback.
+type Source struct {
+	Url           string
+	StartOffset   time.Duration
+	EndOffset     time.Duration
+	Resolve       ResolveFunc
+	Stream        bool
+	Hls           bool
+	OnStreamTitle func(title string)
+	Overlay       bool
+	Gain          float32
 }
 
 type AudioSink interface {
@@ -19,105 +59,195 @@ type AudioSink interface {
 	OnFinished()
 	OnStopped()
 	OnFailed()
+	// SetSpeaking signals audio of overlays that play while no other media item is playing
+	SetSpeaking(speaking bool)
 	SendOpusFrame(timestamp uint32, frame []byte) error
 }
 
-type audioFrame struct {
-	data   []byte
-	header *oggreader.OggPageHeader
-}
+func NewEncoder(source Source, mixer *Mixer) *Encoder {
+	inputConfig := ""
+	if source.StartOffset > 0 {
+		inputConfig = fmt.Sprintf("-ss %.3f", source.StartOffset.Seconds())
+	}
+
+	outputConfig := "-vn " + pcmFormatConfig
+	if source.EndOffset > source.StartOffset {
+		outputConfig = fmt.Sprintf("-t %.3f ", (source.EndOffset-source.StartOffset).Seconds()) + outputConfig
+	}
+
+	sourceUrl := source.Url
+	var stdin io.ReadCloser
+	if source.Stream {
+		inputConfig = ""
+		sourceUrl = "pipe:0"
+		stdin = NewIcyReader(source.Url, source.OnStreamTitle)
+	} else if source.Hls {
+		// ffmpeg follows the playlist itself. Live streams start with their most recent segment
+		inputConfig = strings.TrimSpace("-live_start_index -1 " + inputConfig)
+	} else if isRemoteUrl(source.Url) && source.StartOffset > 0 {
+		// A pipe can only be read from its start, so ffmpeg requests the range at the offset itself
+		inputConfig = seekingHttpConfig + " " + inputConfig
+	} else if isRemoteUrl(source.Url) {
+		sourceUrl = "pipe:0"
+		stdin = NewRangeReader(source.Url, source.Resolve)
+	}
+
+	if source.Gain == 0 {
+		source.Gain = 1
+	}
 
-func NewEncoder(url string, sink AudioSink) *Encoder {
 	return &Encoder{
 		ffmpeg: Ffmpeg{
-			Executable: config.GetString(config.KeyFfmpegLocation),
-			SourceUrl:  url,
+			Executable:  config.GetString(config.KeyFfmpegLocation),
+			SourceUrl:   sourceUrl,
+			InputConfig: inputConfig,
+			Stdin:       stdin,
 			OutputStreams: []OutputStream{
 				{
 					Number: 1,
-					Config: "-c:a libopus -b:a 48K -vn -page_duration 20000 -f ogg",
+					Config: outputConfig,
 				},
 			},
 		},
-		sink:     sink,
-		stopChan: make(chan interface{}),
+		source: source,
+		mixer:  mixer,
+		ended:  make(chan interface{}),
 	}
 }
 
+// Start starts playing the source. Unless it is an overlay, it becomes the primary input of the mixer,
+// so that only its playback is reported to the AudioSink from then on.
 func (encoder *Encoder) Start() error {
 	err := encoder.ffmpeg.Start()
 	if err != nil {
 		return err
 	}
 
-	oggReader, _, err := oggreader.NewWith(encoder.ffmpeg.Stdout)
-	if err != nil {
-		return err
+	encoder.attach(encoder.ffmpeg.Stdout)
+	return nil
+}
+
+// attach adds the PCM output of the encoder to the mixer and notifies the AudioSink
+func (encoder *Encoder) attach(pcm io.Reader) {
+	encoder.input = encoder.mixer.AddInput(pcm, encoder.source.Gain, encoder.source.Overlay, encoder.onInputEnded)
+	if !encoder.source.Overlay {
+		encoder.mixer.setPrimary(encoder.input)
+		encoder.mixer.sink.OnBegin()
+	} else if !encoder.mixer.hasActivePrimary() {
+		encoder.speaking = true
+		encoder.mixer.sink.SetSpeaking(true)
 	}
+}
+
+// Stop removes the encoder from the mix and notifies the AudioSink using OnStopped before returning.
+// It does nothing if the encoder was never started.
+func (encoder *Encoder) Stop() {
+	if encoder.input == nil {
+		return
+	}
+
+	encoder.endOnce.Do(func() {
+		encoder.mixer.RemoveInput(encoder.input)
+		encoder.ffmpeg.Stop()
+		encoder.end(EndStopped, AudioSink.OnStopped)
+
+		go func() {
+			<-encoder.input.Done()
+			_ = encoder.ffmpeg.Wait()
+		}()
+	})
+}
 
-	ticker := time.NewTicker(20 * time.Millisecond)
-	encoder.sink.OnBegin()
-
-	pageChan := make(chan audioFrame, 300000)
-
-	go func() {
-		zap.S().Debugln("Audio buffer is starting")
-		for {
-			select {
-			case <-encoder.stopChan:
-				zap.S().Debugln("Audio buffering was stopped")
-				return
-			default:
-				pageData, pageHeader, err := oggReader.ParseNextPage()
-				if err == io.EOF {
-					zap.S().Debugln("Audio buffering completed")
-					pageChan <- audioFrame{
-						data:   nil,
-						header: nil,
-					}
-					return
-				} else if err != nil {
-					zap.S().Warnw("Audio buffering failed", "error", err)
-					return
-				}
-
-				pageChan <- audioFrame{
-					data:   pageData,
-					header: pageHeader,
-				}
-			}
+func (encoder *Encoder) onInputEnded(input *MixerInput) {
+	encoder.endOnce.Do(func() {
+		<-input.Done()
+		err := encoder.ffmpeg.Wait()
+		if err == nil {
+			err = input.Err()
 		}
-	}()
-
-	go func() {
-		zap.S().Debugln("Audio streamer is starting")
-		defer encoder.ffmpeg.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				page := <-pageChan
-				if page.header == nil && page.data == nil {
-					zap.S().Debugln("Audio streaming completed")
-					encoder.sink.OnFinished()
-					return
-				}
-				err = encoder.sink.SendOpusFrame(uint32(page.header.GranulePosition), page.data)
-				if err != nil {
-					zap.S().Warnw("Failed to write audio frame to stream", "error", err)
-					encoder.sink.OnFailed()
-					return
-				}
-			case <-encoder.stopChan:
-				zap.S().Debugln("Audio streaming was stopped")
-				encoder.sink.OnStopped()
-				return
-			}
+
+		if err != nil {
+			encoder.setErr(err)
+			encoder.end(EndFailed, AudioSink.OnFailed)
+		} else {
+			encoder.end(EndFinished, AudioSink.OnFinished)
 		}
-	}()
+	})
+}
 
-	return nil
+// end records why the encoder ended, and notifies the AudioSink unless another encoder took over the mixer since
+func (encoder *Encoder) end(reason EndReason, notify func(sink AudioSink)) {
+	encoder.endReason = reason
+	if !encoder.source.Overlay && encoder.mixer.isPrimary(encoder.input) {
+		notify(encoder.mixer.sink)
+	} else if encoder.speaking && !encoder.mixer.hasActivePrimary() {
+		encoder.mixer.sink.SetSpeaking(false)
+	}
+	close(encoder.ended)
 }
 
-func (encoder *Encoder) Stop() {
-	close(encoder.stopChan)
+// Done is closed once the encoder stopped producing audio, because it finished, failed or was stopped.
+// It is closed immediately if the encoder was never started.
+func (encoder *Encoder) Done() <-chan interface{} {
+	if encoder.input == nil {
+		done := make(chan interface{})
+		close(done)
+		return done
+	}
+	return encoder.input.Done()
+}
+
+// Ended is closed once the encoder ended and the AudioSink was notified. EndReason tells why it ended.
+func (encoder *Encoder) Ended() <-chan interface{} {
+	return encoder.ended
+}
+
+// EndReason returns why the encoder ended. It is only valid after Ended was closed
+func (encoder *Encoder) EndReason() EndReason {
+	return encoder.endReason
+}
+
+// Running checks whether the encoder was started and did not stop producing audio yet
+func (encoder *Encoder) Running() bool {
+	if encoder.input == nil {
+		return false
+	}
+	select {
+	case <-encoder.input.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// Position returns the playback position within the source media
+func (encoder *Encoder) Position() time.Duration {
+	if encoder.input == nil {
+		return encoder.source.StartOffset
+	}
+	return encoder.source.StartOffset + encoder.input.Played()
+}
+
+// Err returns the reason why the encoder failed, or nil if it did not fail because of its input.
+// It is set before the AudioSink is notified using OnFailed
+func (encoder *Encoder) Err() error {
+	encoder.errMutex.Lock()
+	defer encoder.errMutex.Unlock()
+	return encoder.err
+}
+
+// IsHlsUrl checks whether the URL points to an HLS playlist, as returned for live streams
+func IsHlsUrl(url string) bool {
+	path := strings.SplitN(url, "?", 2)[0]
+	return strings.HasSuffix(path, ".m3u8") || strings.Contains(path, "/hls_playlist/") || strings.Contains(path, "/hls_variant/")
+}
+
+func isRemoteUrl(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func (encoder *Encoder) setErr(err error) {
+	encoder.errMutex.Lock()
+	defer encoder.errMutex.Unlock()
+	encoder.err = err
 }
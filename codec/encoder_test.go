@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoderStopBeforeStart(t *testing.T) {
+	sink := &testSink{}
+	encoder := NewEncoder(Source{Url: "test.mp3"}, NewMixer(sink))
+
+	encoder.Stop()
+	select {
+	case <-encoder.Done():
+	default:
+		t.Fatal("expected Done to be closed for an encoder that was never started")
+	}
+	if encoder.Running() {
+		t.Error("expected an encoder that was never started not to run")
+	}
+	if sink.events() != "" {
+		t.Errorf("expected no sink notifications, got %q", sink.events())
+	}
+}
+
+// startTestEncoder starts an encoder that plays the reader instead of the output of ffmpeg
+func startTestEncoder(mixer *Mixer, reader io.Reader) *Encoder {
+	return startTestSource(mixer, Source{Url: "test.mp3"}, reader)
+}
+
+func startTestSource(mixer *Mixer, source Source, reader io.Reader) *Encoder {
+	encoder := NewEncoder(source, mixer)
+	encoder.attach(reader)
+	return encoder
+}
+
+func TestEncoderStopNotifiesBeforeReturning(t *testing.T) {
+	sink := &testSink{}
+	mixer := NewMixer(sink)
+	first := startTestEncoder(mixer, bytes.NewReader(make([]byte, FrameSize*20)))
+
+	first.Stop()
+	if sink.events() != "begin,stopped" {
+		t.Fatalf("expected the stop to be reported before Stop returns, got %q", sink.events())
+	}
+	select {
+	case <-first.Ended():
+	default:
+		t.Fatal("expected Ended to be closed after Stop")
+	}
+	if first.EndReason() != EndStopped {
+		t.Errorf("expected EndStopped, got %d", first.EndReason())
+	}
+}
+
+func TestEncoderIgnoresEndAfterReplacement(t *testing.T) {
+	sink := &testSink{}
+	mixer := NewMixer(sink)
+	first := startTestEncoder(mixer, bytes.NewReader(make([]byte, FrameSize*20)))
+	startTestEncoder(mixer, bytes.NewReader(make([]byte, FrameSize*20)))
+
+	// The first encoder ends late, after the second one took over the mixer
+	first.end(EndFinished, AudioSink.OnFinished)
+	if sink.events() != "begin,begin" {
+		t.Fatalf("expected the end of a replaced encoder not to be reported, got %q", sink.events())
+	}
+}
+
+func TestOverlaySpeaksWhileNothingElsePlays(t *testing.T) {
+	sink := &testSink{}
+	mixer := NewMixer(sink)
+	overlay := startTestSource(mixer, Source{Url: "sfx.mp3", Overlay: true}, bytes.NewReader(make([]byte, FrameSize*20)))
+
+	overlay.Stop()
+	if sink.events() != "speaking,silent" {
+		t.Fatalf("expected an overlay on its own to signal speaking, got %q", sink.events())
+	}
+}
+
+func TestOverlayDoesNotSpeakOverPrimary(t *testing.T) {
+	sink := &testSink{}
+	mixer := NewMixer(sink)
+	startTestEncoder(mixer, bytes.NewReader(make([]byte, FrameSize*20)))
+	overlay := startTestSource(mixer, Source{Url: "sfx.mp3", Overlay: true}, bytes.NewReader(make([]byte, FrameSize*20)))
+
+	overlay.Stop()
+	if sink.events() != "begin" {
+		t.Fatalf("expected the overlay not to change the speaking state of the playing item, got %q", sink.events())
+	}
+}
+
+func TestEncoderSeeksInRemoteSources(t *testing.T) {
+	mixer := NewMixer(&testSink{})
+	url := "https://example.com/audio.webm"
+
+	encoder := NewEncoder(Source{Url: url}, mixer)
+	if encoder.ffmpeg.SourceUrl != "pipe:0" || encoder.ffmpeg.Stdin == nil {
+		t.Errorf("expected remote sources to be read through a pipe, got `%s`", encoder.ffmpeg.SourceUrl)
+	}
+
+	// Reading the pipe from its start would download the media until the offset
+	encoder = NewEncoder(Source{Url: url, StartOffset: 90 * time.Second}, mixer)
+	if encoder.ffmpeg.SourceUrl != url || encoder.ffmpeg.Stdin != nil {
+		t.Errorf("expected ffmpeg to read the remote source itself when seeking, got `%s`", encoder.ffmpeg.SourceUrl)
+	}
+	if !strings.HasSuffix(encoder.ffmpeg.InputConfig, "-ss 90.000") {
+		t.Errorf("expected the input to start at the offset, got `%s`", encoder.ffmpeg.InputConfig)
+	}
+}
@@ -1,20 +1,32 @@
 package codec
 
 import (
+	"context"
+	"errors"
 	"go.uber.org/zap"
 	"io"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+const stderrCapacity = 4096
+
 type Ffmpeg struct {
 	Executable    string
 	SourceUrl     string
+	InputConfig   string
+	Stdin         io.ReadCloser
 	OutputStreams []OutputStream
 	Command       *exec.Cmd
 	Stdout        io.Reader
-	Stderr        io.Reader
+	Stderr        *LogBuffer
+
+	stderrDone chan interface{}
+	exited     chan interface{}
+	stopMutex  sync.Mutex
+	killed     bool
 }
 
 type OutputStream struct {
@@ -25,6 +37,9 @@ type OutputStream struct {
 func (ffmpeg *Ffmpeg) Start() error {
 	cmd := exec.Command(ffmpeg.Executable, ffmpeg.buildArguments()...)
 	ffmpeg.Command = cmd
+	if ffmpeg.Stdin != nil {
+		cmd.Stdin = ffmpeg.Stdin
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -36,12 +51,69 @@ func (ffmpeg *Ffmpeg) Start() error {
 	if err != nil {
 		return err
 	}
-	ffmpeg.Stderr = stderr
+	ffmpeg.Stderr = NewLogBuffer(stderrCapacity)
+	ffmpeg.stderrDone = make(chan interface{})
+	ffmpeg.exited = make(chan interface{})
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		_, _ = io.Copy(ffmpeg.Stderr, stderr)
+		close(ffmpeg.stderrDone)
+	}()
 
-	return cmd.Start()
+	return nil
+}
+
+// Wait waits for the ffmpeg process to exit and returns an *FfmpegError if it failed.
+// It must only be called once, and only after Stdout has been fully consumed
+func (ffmpeg *Ffmpeg) Wait() error {
+	<-ffmpeg.stderrDone
+	if ffmpeg.Stdin != nil {
+		// Unblocks the goroutine copying to stdin, which Wait waits for
+		_ = ffmpeg.Stdin.Close()
+	}
+	err := ffmpeg.Command.Wait()
+	close(ffmpeg.exited)
+
+	ffmpeg.stopMutex.Lock()
+	killed := ffmpeg.killed
+	ffmpeg.stopMutex.Unlock()
+
+	if err == nil || killed || errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// ffmpeg exited gracefully, but copying its input failed
+		return &FfmpegError{ExitCode: 0, Output: err.Error(), Kind: ErrConnectionReset}
+	}
+
+	ffmpegErr := classifyFfmpegError(exitErr.ExitCode(), ffmpeg.Stderr.String())
+	zap.S().Warnw("ffmpeg exited with an error", "exitCode", ffmpegErr.ExitCode, "kind", ffmpegErr.Kind, "output", ffmpegErr.Output)
+	return ffmpegErr
 }
 
 func (ffmpeg *Ffmpeg) Stop() {
+	ffmpeg.stopMutex.Lock()
+	defer ffmpeg.stopMutex.Unlock()
+
+	if ffmpeg.Command == nil || ffmpeg.Command.Process == nil {
+		// ffmpeg was never started
+		return
+	}
+
+	select {
+	case <-ffmpeg.exited:
+		return
+	default:
+	}
+
+	ffmpeg.killed = true
 	err := ffmpeg.Command.Process.Kill()
 	if err != nil {
 		zap.S().Warnw("Failed to stop ffmpeg process, could already be dead.", "error", err)
@@ -49,7 +121,11 @@ func (ffmpeg *Ffmpeg) Stop() {
 }
 
 func (ffmpeg *Ffmpeg) buildArguments() []string {
-	arguments := []string{"-loglevel", "error", "-i", ffmpeg.SourceUrl}
+	arguments := []string{"-loglevel", "error"}
+	if len(ffmpeg.InputConfig) > 0 {
+		arguments = append(arguments, strings.Split(ffmpeg.InputConfig, " ")...)
+	}
+	arguments = append(arguments, "-i", ffmpeg.SourceUrl)
 
 	for _, stream := range ffmpeg.OutputStreams {
 		streamArgs := strings.Split(stream.Config, " ")
@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrHttpForbidden    = errors.New("the media server refused access to the stream")
+	ErrHttpNotFound     = errors.New("the stream could not be found on the media server")
+	ErrConnectionReset  = errors.New("the connection to the media server was lost")
+	ErrUnsupportedCodec = errors.New("the stream uses an unsupported format or codec")
+	ErrUnknown          = errors.New("ffmpeg exited unexpectedly")
+)
+
+// FfmpegError describes a failed ffmpeg run. Kind is one of the Err* values
+// of this package and can be checked using errors.Is
+type FfmpegError struct {
+	ExitCode int
+	Output   string
+	Kind     error
+}
+
+func (err *FfmpegError) Error() string {
+	return err.Kind.Error() + " (exit code " + strconv.Itoa(err.ExitCode) + "): " + err.Output
+}
+
+func (err *FfmpegError) Unwrap() error {
+	return err.Kind
+}
+
+// IsRecoverable returns true if the error can likely be resolved by re-resolving
+// the stream URL and resuming the playback
+func IsRecoverable(err error) bool {
+	return errors.Is(err, ErrHttpForbidden) || errors.Is(err, ErrConnectionReset)
+}
+
+var errorPatterns = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrHttpForbidden, []string{"403 forbidden", "http error 403", "410 gone", "http error 410"}},
+	{ErrHttpNotFound, []string{"404 not found", "http error 404"}},
+	{ErrConnectionReset, []string{"connection reset by peer", "connection timed out", "broken pipe", "i/o error", "end of file"}},
+	{ErrUnsupportedCodec, []string{"unknown decoder", "decoder not found", "invalid data found when processing input", "could not find codec parameters", "unsupported codec"}},
+}
+
+func classifyFfmpegError(exitCode int, output string) *FfmpegError {
+	lowerOutput := strings.ToLower(output)
+	kind := ErrUnknown
+
+	for _, candidate := range errorPatterns {
+		for _, pattern := range candidate.patterns {
+			if strings.Contains(lowerOutput, pattern) {
+				kind = candidate.kind
+				break
+			}
+		}
+		if kind != ErrUnknown {
+			break
+		}
+	}
+
+	return &FfmpegError{
+		ExitCode: exitCode,
+		Output:   output,
+		Kind:     kind,
+	}
+}
@@ -0,0 +1,105 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"ytbot/config"
+)
+
+const (
+	probeTimeout = 30 * time.Second
+	// probeIoTimeout is the time in microseconds after which ffprobe gives up on a stalled remote input
+	probeIoTimeout = "15000000"
+)
+
+var (
+	ErrNoAudio      = errors.New("the media does not contain an audio stream")
+	ErrProbeTimeout = errors.New("reading the media took too long")
+)
+
+// ProbeResult contains the metadata of a media file as reported by ffprobe
+type ProbeResult struct {
+	Title    string
+	Artist   string
+	Duration time.Duration
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// Probe reads the metadata of a local file or remote URL using ffprobe
+func Probe(url string) (ProbeResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	args := []string{"-v", "error"}
+	if strings.Contains(url, "://") {
+		args = append(args, "-rw_timeout", probeIoTimeout)
+	}
+	args = append(args,
+		"-show_entries", "stream=codec_type:format=duration:format_tags",
+		"-of", "json",
+		url,
+	)
+	cmd := exec.CommandContext(ctx, config.GetString(config.KeyFfprobeLocation), args...)
+
+	stderr := NewLogBuffer(stderrCapacity)
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ProbeResult{}, ErrProbeTimeout
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return ProbeResult{}, classifyFfmpegError(exitErr.ExitCode(), stderr.String())
+		}
+		return ProbeResult{}, err
+	}
+
+	var probe probeOutput
+	err = json.Unmarshal(output, &probe)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+
+	hasAudio := false
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "audio" {
+			hasAudio = true
+		}
+	}
+	if !hasAudio {
+		return ProbeResult{}, ErrNoAudio
+	}
+
+	result := ProbeResult{}
+	for key, value := range probe.Format.Tags {
+		switch strings.ToLower(key) {
+		case "title":
+			result.Title = value
+		case "artist":
+			result.Artist = value
+		}
+	}
+
+	seconds, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err == nil {
+		result.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return result, nil
+}
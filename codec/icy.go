@@ -0,0 +1,205 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// IcyClient is an HTTP client that also understands the `ICY 200 OK` status line of old Shoutcast servers
+var IcyClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &icyConn{Conn: conn}, nil
+		},
+	},
+}
+
+// StreamInfo describes an internet radio stream
+type StreamInfo struct {
+	Name          string
+	ContentType   string
+	ContentLength int64
+	MetaInt       int
+}
+
+// OpenStream connects to an internet radio stream, requesting ICY metadata
+func OpenStream(ctx context.Context, url string) (*http.Response, StreamInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, StreamInfo{}, err
+	}
+	req.Header.Set("Icy-MetaData", "1")
+
+	resp, err := IcyClient.Do(req)
+	if err != nil {
+		return nil, StreamInfo{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, StreamInfo{}, fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	metaInt, _ := strconv.Atoi(resp.Header.Get("Icy-Metaint"))
+	return resp, StreamInfo{
+		Name:          resp.Header.Get("Icy-Name"),
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		MetaInt:       metaInt,
+	}, nil
+}
+
+// IsStream checks if the stream info belongs to an endless audio stream rather than an audio file
+func (info StreamInfo) IsStream() bool {
+	if info.MetaInt > 0 || len(info.Name) > 0 {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(info.ContentType), "audio/") && info.ContentLength < 0
+}
+
+// IcyReader reads an internet radio stream, removing the interleaved ICY metadata blocks
+// and reporting changes of the stream title to OnTitle
+type IcyReader struct {
+	Url     string
+	OnTitle func(title string)
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	body      io.ReadCloser
+	metaInt   int
+	remaining int
+	title     string
+}
+
+func NewIcyReader(url string, onTitle func(title string)) *IcyReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &IcyReader{
+		Url:     url,
+		OnTitle: onTitle,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (reader *IcyReader) Read(p []byte) (int, error) {
+	if reader.body == nil {
+		resp, info, err := OpenStream(reader.ctx, reader.Url)
+		if err != nil {
+			return 0, err
+		}
+		reader.body = resp.Body
+		reader.metaInt = info.MetaInt
+		reader.remaining = info.MetaInt
+	}
+
+	if reader.metaInt <= 0 {
+		return reader.body.Read(p)
+	}
+
+	if reader.remaining == 0 {
+		err := reader.readMetadata()
+		if err != nil {
+			return 0, err
+		}
+		reader.remaining = reader.metaInt
+	}
+
+	if len(p) > reader.remaining {
+		p = p[:reader.remaining]
+	}
+	n, err := reader.body.Read(p)
+	reader.remaining -= n
+	return n, err
+}
+
+// Close aborts all pending and future reads. It may be called from another goroutine
+func (reader *IcyReader) Close() error {
+	reader.cancel()
+	return nil
+}
+
+func (reader *IcyReader) readMetadata() error {
+	lengthByte := make([]byte, 1)
+	_, err := io.ReadFull(reader.body, lengthByte)
+	if err != nil {
+		return err
+	}
+
+	metadata := make([]byte, int(lengthByte[0])*16)
+	_, err = io.ReadFull(reader.body, metadata)
+	if err != nil {
+		return err
+	}
+
+	title, ok := parseStreamTitle(string(bytes.TrimRight(metadata, "\x00")))
+	if ok && title != reader.title {
+		reader.title = title
+		if reader.OnTitle != nil {
+			reader.OnTitle(title)
+		}
+	}
+	return nil
+}
+
+// parseStreamTitle extracts the title from ICY metadata like `StreamTitle='Artist - Song';`
+func parseStreamTitle(metadata string) (string, bool) {
+	const prefix = "StreamTitle='"
+	start := strings.Index(metadata, prefix)
+	if start < 0 {
+		return "", false
+	}
+	value := metadata[start+len(prefix):]
+
+	end := strings.Index(value, "';")
+	if end < 0 {
+		end = strings.LastIndex(value, "'")
+	}
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(value[:end]), true
+}
+
+// icyConn rewrites the `ICY` status line of Shoutcast v1 servers into a valid HTTP/1.0 status line
+type icyConn struct {
+	net.Conn
+	checked bool
+	pending []byte
+}
+
+func (conn *icyConn) Read(p []byte) (int, error) {
+	if !conn.checked {
+		conn.checked = true
+
+		head := make([]byte, 4)
+		n, err := io.ReadFull(conn.Conn, head)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, err
+		}
+		head = head[:n]
+		if string(head) == "ICY " {
+			head = []byte("HTTP/1.0 ")
+		}
+		conn.pending = head
+	}
+
+	if len(conn.pending) > 0 {
+		n := copy(p, conn.pending)
+		conn.pending = conn.pending[n:]
+		return n, nil
+	}
+
+	return conn.Conn.Read(p)
+}
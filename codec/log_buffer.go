@@ -0,0 +1,39 @@
+package codec
+
+import (
+	"strings"
+	"sync"
+)
+
+// LogBuffer is an io.Writer that only keeps the last Capacity bytes written to it
+type LogBuffer struct {
+	Capacity int
+
+	data  []byte
+	mutex sync.Mutex
+}
+
+func NewLogBuffer(capacity int) *LogBuffer {
+	return &LogBuffer{
+		Capacity: capacity,
+		data:     make([]byte, 0, capacity),
+	}
+}
+
+func (buf *LogBuffer) Write(p []byte) (int, error) {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+
+	buf.data = append(buf.data, p...)
+	if overflow := len(buf.data) - buf.Capacity; overflow > 0 {
+		buf.data = append(buf.data[:0], buf.data[overflow:]...)
+	}
+
+	return len(p), nil
+}
+
+func (buf *LogBuffer) String() string {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+	return strings.TrimSpace(string(buf.data))
+}
@@ -0,0 +1,380 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pion/webrtc/v3/pkg/media/oggreader"
+	"go.uber.org/zap"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+	"ytbot/config"
+)
+
+const (
+	defaultDuckGain   = 0.3
+	duckRampStep      = 0.1
+	silenceTailFrames = 5
+	inputBufferFrames = 50
+)
+
+// Mixer mixes the PCM audio of multiple inputs in real time and encodes the mix to Opus once,
+// sending it to an AudioSink. While an input that ducks is active, all other inputs are
+// attenuated to DuckGain.
+type Mixer struct {
+	DuckGain float32
+
+	sink      AudioSink
+	ffmpeg    Ffmpeg
+	pcmWriter *io.PipeWriter
+	inputs    []*MixerInput
+	primary   *MixerInput
+	mixBuffer []float32
+	duckLevel float32
+	silence   int
+	mutex     sync.Mutex
+	stopChan  chan interface{}
+	stopOnce  sync.Once
+}
+
+// MixerInput is a source of PCM audio in a Mixer
+type MixerInput struct {
+	Gain  float32
+	Ducks bool
+
+	frames  chan []int16
+	removed chan interface{}
+	done    chan interface{}
+	onEnd   func(input *MixerInput)
+	mixed   uint64
+	err     error
+}
+
+func NewMixer(sink AudioSink) *Mixer {
+	pcmReader, pcmWriter := io.Pipe()
+	return &Mixer{
+		DuckGain: defaultDuckGain,
+		sink:     sink,
+		ffmpeg: Ffmpeg{
+			Executable:  config.GetString(config.KeyFfmpegLocation),
+			SourceUrl:   "pipe:0",
+			InputConfig: pcmFormatConfig,
+			Stdin:       pcmReader,
+			OutputStreams: []OutputStream{
+				{
+					Number: 1,
+					Config: "-c:a libopus -b:a 48K -page_duration 20000 -flush_packets 1 -f ogg",
+				},
+			},
+		},
+		pcmWriter: pcmWriter,
+		mixBuffer: make([]float32, FrameSize),
+		duckLevel: 1,
+		stopChan:  make(chan interface{}),
+	}
+}
+
+// Sink returns the AudioSink that the mix is sent to
+func (mixer *Mixer) Sink() AudioSink {
+	return mixer.sink
+}
+
+func (mixer *Mixer) Start() error {
+	err := mixer.ffmpeg.Start()
+	if err != nil {
+		return err
+	}
+
+	go mixer.runMixLoop()
+	go mixer.runOutputLoop()
+
+	return nil
+}
+
+func (mixer *Mixer) Stop() {
+	mixer.stopOnce.Do(func() {
+		close(mixer.stopChan)
+		_ = mixer.pcmWriter.Close()
+		mixer.ffmpeg.Stop()
+	})
+}
+
+// AddInput adds a reader of PCM audio to the mix. The reader is consumed in the background,
+// and onEnd is called once it is exhausted. It is not called for inputs that are removed.
+func (mixer *Mixer) AddInput(reader io.Reader, gain float32, ducks bool, onEnd func(input *MixerInput)) *MixerInput {
+	input := &MixerInput{
+		Gain:    gain,
+		Ducks:   ducks,
+		frames:  make(chan []int16, inputBufferFrames),
+		removed: make(chan interface{}),
+		done:    make(chan interface{}),
+		onEnd:   onEnd,
+	}
+	go input.run(reader)
+
+	mixer.mutex.Lock()
+	mixer.inputs = append(mixer.inputs, input)
+	mixer.mutex.Unlock()
+
+	return input
+}
+
+func (mixer *Mixer) RemoveInput(input *MixerInput) {
+	mixer.mutex.Lock()
+	defer mixer.mutex.Unlock()
+
+	for idx, candidate := range mixer.inputs {
+		if candidate == input {
+			mixer.inputs = append(mixer.inputs[:idx:idx], mixer.inputs[idx+1:]...)
+			close(input.removed)
+			return
+		}
+	}
+}
+
+// setPrimary marks the input as the one whose playback the AudioSink is notified about
+func (mixer *Mixer) setPrimary(input *MixerInput) {
+	mixer.mutex.Lock()
+	defer mixer.mutex.Unlock()
+	mixer.primary = input
+}
+
+func (mixer *Mixer) isPrimary(input *MixerInput) bool {
+	mixer.mutex.Lock()
+	defer mixer.mutex.Unlock()
+	return mixer.primary == input
+}
+
+// hasActivePrimary checks whether the primary input is still part of the mix
+func (mixer *Mixer) hasActivePrimary() bool {
+	mixer.mutex.Lock()
+	defer mixer.mutex.Unlock()
+	for _, input := range mixer.inputs {
+		if input == mixer.primary {
+			return true
+		}
+	}
+	return false
+}
+
+// MixFrame mixes the next frame of all inputs into out, which must have a length of FrameSize.
+// Inputs that have no data available contribute silence. It returns false if there is nothing to send.
+func (mixer *Mixer) MixFrame(out []int16) bool {
+	mixer.mutex.Lock()
+
+	for i := range mixer.mixBuffer {
+		mixer.mixBuffer[i] = 0
+	}
+
+	ducking := false
+	for _, input := range mixer.inputs {
+		if input.Ducks {
+			ducking = true
+		}
+	}
+	mixer.rampDuckLevel(ducking)
+
+	ended := make([]*MixerInput, 0)
+	remaining := mixer.inputs[:0]
+	for _, input := range mixer.inputs {
+		select {
+		case frame, ok := <-input.frames:
+			if !ok {
+				ended = append(ended, input)
+				continue
+			}
+
+			gain := input.Gain
+			if !input.Ducks {
+				gain *= mixer.duckLevel
+			}
+			for i, sample := range frame {
+				mixer.mixBuffer[i] += float32(sample) * gain
+			}
+			atomic.AddUint64(&input.mixed, 1)
+		default:
+			// Input is buffering, it contributes silence to this frame
+		}
+		remaining = append(remaining, input)
+	}
+	mixer.inputs = remaining
+	active := len(mixer.inputs) > 0
+
+	mixer.mutex.Unlock()
+
+	for _, input := range ended {
+		if input.onEnd != nil {
+			go input.onEnd(input)
+		}
+	}
+
+	if active {
+		mixer.silence = 0
+	} else if mixer.silence < silenceTailFrames {
+		// Send a few frames of silence after the last input to avoid interpolation artifacts
+		mixer.silence++
+	} else {
+		return false
+	}
+
+	for i, sample := range mixer.mixBuffer {
+		out[i] = clampSample(sample)
+	}
+	return true
+}
+
+func (mixer *Mixer) rampDuckLevel(ducking bool) {
+	target := float32(1)
+	if ducking {
+		target = mixer.DuckGain
+	}
+
+	if mixer.duckLevel > target {
+		mixer.duckLevel = maxFloat(mixer.duckLevel-duckRampStep, target)
+	} else if mixer.duckLevel < target {
+		mixer.duckLevel = minFloat(mixer.duckLevel+duckRampStep, target)
+	}
+}
+
+func (mixer *Mixer) runMixLoop() {
+	zap.S().Debugln("Audio mixer is starting")
+	ticker := time.NewTicker(FrameDuration)
+	defer ticker.Stop()
+
+	frame := make([]int16, FrameSize)
+	data := make([]byte, FrameSize*2)
+	for {
+		select {
+		case <-ticker.C:
+			if !mixer.MixFrame(frame) {
+				continue
+			}
+			encodePcm(frame, data)
+			_, err := mixer.pcmWriter.Write(data)
+			if err != nil {
+				mixer.fail(err)
+				return
+			}
+		case <-mixer.stopChan:
+			zap.S().Debugln("Audio mixer was stopped")
+			return
+		}
+	}
+}
+
+func (mixer *Mixer) runOutputLoop() {
+	defer func() {
+		_, _ = io.Copy(io.Discard, mixer.ffmpeg.Stdout)
+		err := mixer.ffmpeg.Wait()
+		if err != nil {
+			mixer.fail(err)
+		}
+	}()
+
+	oggReader, _, err := oggreader.NewWith(mixer.ffmpeg.Stdout)
+	if err != nil {
+		mixer.fail(err)
+		return
+	}
+
+	for {
+		pageData, pageHeader, err := oggReader.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			mixer.fail(errors.New("opus encoder exited"))
+			return
+		} else if err != nil {
+			mixer.fail(err)
+			return
+		}
+
+		if bytes.HasPrefix(pageData, []byte("OpusTags")) {
+			continue
+		}
+
+		err = mixer.sink.SendOpusFrame(uint32(pageHeader.GranulePosition), pageData)
+		if err != nil {
+			mixer.fail(err)
+			return
+		}
+	}
+}
+
+func (mixer *Mixer) fail(err error) {
+	select {
+	case <-mixer.stopChan:
+		return
+	default:
+	}
+
+	zap.S().Warnw("Audio mixer failed", "error", err)
+	mixer.Stop()
+	mixer.sink.OnFailed()
+}
+
+func (input *MixerInput) run(reader io.Reader) {
+	defer close(input.done)
+	defer close(input.frames)
+
+	data := make([]byte, FrameSize*2)
+	for {
+		n, err := io.ReadFull(reader, data)
+		if errors.Is(err, io.EOF) {
+			return
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			for i := n; i < len(data); i++ {
+				data[i] = 0
+			}
+		} else if err != nil {
+			input.err = err
+			return
+		}
+
+		frame := make([]int16, FrameSize)
+		decodePcm(data, frame)
+
+		select {
+		case input.frames <- frame:
+		case <-input.removed:
+			return
+		}
+	}
+}
+
+// Done is closed once the input stopped consuming its reader
+func (input *MixerInput) Done() <-chan interface{} {
+	return input.done
+}
+
+// Err returns the error that occurred while reading the input. It is only valid after Done was closed
+func (input *MixerInput) Err() error {
+	return input.err
+}
+
+// Played returns the duration of audio that was mixed from this input
+func (input *MixerInput) Played() time.Duration {
+	return time.Duration(atomic.LoadUint64(&input.mixed)) * FrameDuration
+}
+
+func clampSample(sample float32) int16 {
+	if sample > 32767 {
+		return 32767
+	} else if sample < -32768 {
+		return -32768
+	}
+	return int16(sample)
+}
+
+func minFloat(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,159 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// constantPcm returns frames of PCM audio in which every sample has the same value
+func constantPcm(sample int16, frames int) *bytes.Reader {
+	frame := make([]int16, FrameSize)
+	for i := range frame {
+		frame[i] = sample
+	}
+	data := make([]byte, FrameSize*2)
+	encodePcm(frame, data)
+	return bytes.NewReader(bytes.Repeat(data, frames))
+}
+
+// addBufferedInput adds an input to the mixer, and waits until it buffered its frames or ended
+func addBufferedInput(t *testing.T, mixer *Mixer, reader *bytes.Reader, gain float32, ducks bool, onEnd func(input *MixerInput)) *MixerInput {
+	t.Helper()
+	frames := reader.Len() / (FrameSize * 2)
+	if frames > inputBufferFrames {
+		frames = inputBufferFrames
+	}
+	input := mixer.AddInput(reader, gain, ducks, onEnd)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(input.frames) < frames {
+		select {
+		case <-input.Done():
+			return input
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("input did not buffer its frames in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return input
+}
+
+func mixFrame(t *testing.T, mixer *Mixer) []int16 {
+	t.Helper()
+	out := make([]int16, FrameSize)
+	if !mixer.MixFrame(out) {
+		t.Fatal("expected a frame to be mixed")
+	}
+	return out
+}
+
+func TestMixerSumsAndClampsInputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []int16
+		gains    []float32
+		expected int16
+	}{
+		{"sum", []int16{1000, 2000}, []float32{1, 1}, 3000},
+		{"clamp positive", []int16{20000, 20000}, []float32{1, 1}, 32767},
+		{"clamp negative", []int16{-20000, -20000}, []float32{1, 1}, -32768},
+		{"gain", []int16{1000, 1000}, []float32{0.5, 2}, 2500},
+		{"muted", []int16{4000}, []float32{0}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mixer := NewMixer(&testSink{})
+			for i, sample := range test.samples {
+				addBufferedInput(t, mixer, constantPcm(sample, 1), test.gains[i], false, nil)
+			}
+
+			out := mixFrame(t, mixer)
+			for i, sample := range out {
+				if sample != test.expected {
+					t.Fatalf("sample %d is %d, expected %d", i, sample, test.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestMixerRampsDuckLevel(t *testing.T) {
+	mixer := NewMixer(&testSink{})
+	music := addBufferedInput(t, mixer, constantPcm(10000, 20), 1, false, nil)
+	effect := addBufferedInput(t, mixer, constantPcm(0, 10), 1, true, nil)
+
+	// The music is attenuated in steps until it reaches the duck gain
+	expected := []int16{9000, 8000, 7000, 6000, 5000, 4000, 3000, 3000}
+	for i, level := range expected {
+		out := mixFrame(t, mixer)
+		if diff := int(out[0]) - int(level); diff < -1 || diff > 1 {
+			t.Fatalf("frame %d has level %d, expected %d", i, out[0], level)
+		}
+	}
+
+	// Once the effect is removed, the music is ramped back up
+	mixer.RemoveInput(effect)
+	out := mixFrame(t, mixer)
+	if diff := int(out[0]) - 4000; diff < -1 || diff > 1 {
+		t.Fatalf("expected the level to rise to 4000 after ducking ended, got %d", out[0])
+	}
+	if music.Played() != 9*FrameDuration {
+		t.Errorf("expected 9 frames of music to be played, got %v", music.Played())
+	}
+}
+
+func TestMixerSendsSilenceTailAndReportsEnd(t *testing.T) {
+	mixer := NewMixer(&testSink{})
+	ended := make(chan *MixerInput, 1)
+	input := addBufferedInput(t, mixer, constantPcm(1000, 2), 1, false, func(input *MixerInput) {
+		ended <- input
+	})
+	<-input.Done()
+
+	for i := 0; i < 2; i++ {
+		if out := mixFrame(t, mixer); out[0] != 1000 {
+			t.Fatalf("frame %d has level %d, expected 1000", i, out[0])
+		}
+	}
+
+	// The frame in which the end of the input is noticed is the first frame of the silence tail
+	for i := 0; i < silenceTailFrames; i++ {
+		if out := mixFrame(t, mixer); out[0] != 0 {
+			t.Fatalf("silence frame %d has level %d", i, out[0])
+		}
+	}
+	if mixer.MixFrame(make([]int16, FrameSize)) {
+		t.Fatal("expected no more frames after the silence tail")
+	}
+
+	select {
+	case endedInput := <-ended:
+		if endedInput != input {
+			t.Error("expected onEnd to be called with the ended input")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected onEnd to be called")
+	}
+}
+
+func TestMixerDoesNotReportRemovedInputs(t *testing.T) {
+	mixer := NewMixer(&testSink{})
+	ended := make(chan *MixerInput, 1)
+	input := addBufferedInput(t, mixer, constantPcm(1000, 100), 1, false, func(input *MixerInput) {
+		ended <- input
+	})
+
+	mixer.RemoveInput(input)
+	<-input.Done()
+	mixFrame(t, mixer)
+
+	select {
+	case <-ended:
+		t.Fatal("expected onEnd not to be called for a removed input")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
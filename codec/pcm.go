@@ -0,0 +1,29 @@
+package codec
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// PCM format used between the decoders, the Mixer and the Opus encoder: signed 16-bit little endian stereo at 48 kHz
+const (
+	SampleRate    = 48000
+	Channels      = 2
+	FrameDuration = 20 * time.Millisecond
+	FrameSamples  = SampleRate / int(time.Second/FrameDuration)
+	FrameSize     = FrameSamples * Channels
+)
+
+const pcmFormatConfig = "-f s16le -ar 48000 -ac 2"
+
+func decodePcm(data []byte, frame []int16) {
+	for i := range frame {
+		frame[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+}
+
+func encodePcm(frame []int16, data []byte) {
+	for i, sample := range frame {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+}
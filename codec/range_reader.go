@@ -0,0 +1,262 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultChunkSize  = 10 * 1024 * 1024
+	defaultMaxRetries = 5
+	defaultRetryDelay = 500 * time.Millisecond
+	// defaultReadTimeout is the time after which a stalled connection is dropped and resumed
+	defaultReadTimeout = 15 * time.Second
+)
+
+// rangeClient fails requests whose server does not respond, reads are bounded by RangeReader.ReadTimeout
+var rangeClient = newRangeClient()
+
+var ErrStreamChanged = errors.New("the stream changed its size after re-resolving")
+
+// ResolveFunc resolves a fresh URL for a stream whose signed URL expired
+type ResolveFunc = func() (string, error)
+
+// RangeReader reads a remote file over HTTP using range requests. It fetches the file in chunks,
+// and resumes from the last byte offset when the connection drops. If the server refuses access
+// because the URL expired, a new URL is obtained using Resolve. Connections that stall for longer
+// than ReadTimeout are dropped and resumed as well.
+type RangeReader struct {
+	Url         string
+	Resolve     ResolveFunc
+	Client      *http.Client
+	ChunkSize   int64
+	MaxRetries  int
+	RetryDelay  time.Duration
+	ReadTimeout time.Duration
+
+	ctx           context.Context
+	cancel        context.CancelFunc
+	cancelRequest context.CancelFunc
+	body          io.ReadCloser
+	offset        int64
+	chunkEnd      int64
+	length        int64
+	retries       int
+}
+
+func NewRangeReader(url string, resolve ResolveFunc) *RangeReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RangeReader{
+		Url:         url,
+		Resolve:     resolve,
+		Client:      rangeClient,
+		ChunkSize:   defaultChunkSize,
+		MaxRetries:  defaultMaxRetries,
+		RetryDelay:  defaultRetryDelay,
+		ReadTimeout: defaultReadTimeout,
+		ctx:         ctx,
+		cancel:      cancel,
+		length:      -1,
+	}
+}
+
+func newRangeClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = defaultReadTimeout
+	return &http.Client{Transport: transport}
+}
+
+// Offset returns the number of bytes that were read so far
+func (reader *RangeReader) Offset() int64 {
+	return reader.offset
+}
+
+func (reader *RangeReader) Read(p []byte) (int, error) {
+	for {
+		if reader.body == nil {
+			if reader.length >= 0 && reader.offset >= reader.length {
+				return 0, io.EOF
+			}
+
+			err := reader.connect()
+			if err == io.EOF {
+				return 0, io.EOF
+			} else if err != nil {
+				if reader.ctx.Err() != nil || !reader.shouldRetry() {
+					return 0, err
+				}
+				zap.S().Debugw("Failed to connect to stream, retrying", "offset", reader.offset, "error", err)
+				continue
+			}
+		}
+
+		n, err := reader.readBody(p)
+		reader.offset += int64(n)
+		if err == nil {
+			reader.retries = 0
+			return n, nil
+		}
+
+		_ = reader.body.Close()
+		reader.body = nil
+		reader.cancelRequest()
+
+		if reader.ctx.Err() != nil {
+			return n, reader.ctx.Err()
+		}
+
+		if err == io.EOF && reader.offset > reader.chunkEnd {
+			// Chunk completed normally, continue with the next one
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+
+		zap.S().Debugw("Stream connection dropped, resuming", "offset", reader.offset, "error", err)
+		if n > 0 {
+			return n, nil
+		}
+		if !reader.shouldRetry() {
+			return 0, err
+		}
+	}
+}
+
+// readBody reads from the current connection, and aborts it if no data arrives within the ReadTimeout
+func (reader *RangeReader) readBody(p []byte) (int, error) {
+	if reader.ReadTimeout <= 0 {
+		return reader.body.Read(p)
+	}
+
+	timer := time.AfterFunc(reader.ReadTimeout, reader.cancelRequest)
+	n, err := reader.body.Read(p)
+	if !timer.Stop() && err != nil {
+		zap.S().Debugw("Stream connection stalled", "offset", reader.offset+int64(n), "timeout", reader.ReadTimeout)
+	}
+	return n, err
+}
+
+// Close aborts all pending and future reads. It may be called from another goroutine
+func (reader *RangeReader) Close() error {
+	reader.cancel()
+	return nil
+}
+
+func (reader *RangeReader) shouldRetry() bool {
+	if reader.retries >= reader.MaxRetries {
+		return false
+	}
+	reader.retries++
+
+	select {
+	case <-time.After(reader.RetryDelay * time.Duration(reader.retries)):
+		return true
+	case <-reader.ctx.Done():
+		return false
+	}
+}
+
+func (reader *RangeReader) connect() error {
+	resp, err := reader.request()
+	if err != nil {
+		return err
+	}
+
+	if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone) && reader.Resolve != nil {
+		_ = resp.Body.Close()
+		zap.S().Infow("Stream URL expired, re-resolving", "offset", reader.offset)
+
+		newUrl, err := reader.Resolve()
+		if err != nil {
+			return err
+		}
+		reader.Url = newUrl
+
+		resp, err = reader.request()
+		if err != nil {
+			return err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		end, total := parseContentRange(resp.Header.Get("Content-Range"))
+		if reader.length >= 0 && total >= 0 && total != reader.length {
+			_ = resp.Body.Close()
+			return ErrStreamChanged
+		}
+		reader.length = total
+		if end >= 0 {
+			reader.chunkEnd = end
+		}
+	case http.StatusOK:
+		if reader.offset > 0 {
+			_ = resp.Body.Close()
+			return errors.New("server does not support range requests")
+		}
+		reader.length = resp.ContentLength
+		reader.chunkEnd = resp.ContentLength - 1
+		if resp.ContentLength < 0 {
+			reader.chunkEnd = int64(^uint64(0) >> 1)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		_ = resp.Body.Close()
+		reader.length = reader.offset
+		return io.EOF
+	default:
+		_ = resp.Body.Close()
+		return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	reader.body = resp.Body
+	return nil
+}
+
+func (reader *RangeReader) request() (*http.Response, error) {
+	if reader.cancelRequest != nil {
+		reader.cancelRequest()
+	}
+	ctx, cancel := context.WithCancel(reader.ctx)
+	reader.cancelRequest = cancel
+	req, err := http.NewRequestWithContext(ctx, "GET", reader.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reader.chunkEnd = reader.offset + reader.ChunkSize - 1
+	if reader.length >= 0 && reader.chunkEnd >= reader.length {
+		reader.chunkEnd = reader.length - 1
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(reader.offset, 10)+"-"+strconv.FormatInt(reader.chunkEnd, 10))
+
+	return reader.Client.Do(req)
+}
+
+// parseContentRange parses the last byte position and the total size from a header
+// like `bytes 0-1023/4096`. Unknown values are returned as -1
+func parseContentRange(header string) (int64, int64) {
+	header = strings.TrimPrefix(header, "bytes ")
+	slashIdx := strings.LastIndex(header, "/")
+	dashIdx := strings.Index(header, "-")
+	if slashIdx < 0 || dashIdx < 0 || dashIdx > slashIdx {
+		return -1, -1
+	}
+
+	end, err := strconv.ParseInt(header[dashIdx+1:slashIdx], 10, 64)
+	if err != nil {
+		end = -1
+	}
+	total, err := strconv.ParseInt(header[slashIdx+1:], 10, 64)
+	if err != nil {
+		total = -1
+	}
+	return end, total
+}
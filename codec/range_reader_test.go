@@ -0,0 +1,217 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rangeServer serves payload using range requests. Responses to the requests for which interrupt returns
+// true only contain the first half of the requested range, after which the connection is dropped or stalls.
+type rangeServer struct {
+	payload   []byte
+	interrupt func(request int) bool
+	stall     bool
+	requests  int32
+}
+
+func (server *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := int(atomic.AddInt32(&server.requests, 1))
+
+	var start, end int
+	_, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+	if err != nil || start >= len(server.payload) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if end >= len(server.payload) {
+		end = len(server.payload) - 1
+	}
+	body := server.payload[start : end+1]
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(server.payload)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusPartialContent)
+
+	if server.interrupt == nil || !server.interrupt(request) {
+		_, _ = w.Write(body)
+		return
+	}
+
+	_, _ = w.Write(body[:len(body)/2])
+	w.(http.Flusher).Flush()
+	if server.stall {
+		<-r.Context().Done()
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		_ = conn.Close()
+	}
+}
+
+func newTestPayload(size int) []byte {
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+	return payload
+}
+
+func newTestRangeReader(url string) *RangeReader {
+	reader := NewRangeReader(url, nil)
+	reader.ChunkSize = 16 * 1024
+	reader.RetryDelay = time.Millisecond
+	reader.ReadTimeout = time.Second
+	return reader
+}
+
+func readAllWithTimeout(t *testing.T, reader io.Reader) ([]byte, error) {
+	t.Helper()
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := io.ReadAll(reader)
+		done <- result{data, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-time.After(10 * time.Second):
+		t.Fatal("reading did not finish in time")
+		return nil, nil
+	}
+}
+
+func TestRangeReaderReadsInChunks(t *testing.T) {
+	server := &rangeServer{payload: newTestPayload(100 * 1024)}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	data, err := readAllWithTimeout(t, newTestRangeReader(httpServer.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, server.payload) {
+		t.Fatalf("read %d bytes that differ from the payload", len(data))
+	}
+	if requests := atomic.LoadInt32(&server.requests); requests != 7 {
+		t.Errorf("expected 7 chunk requests, got %d", requests)
+	}
+}
+
+func TestRangeReaderResumesDroppedConnections(t *testing.T) {
+	server := &rangeServer{
+		payload: newTestPayload(100 * 1024),
+		interrupt: func(request int) bool {
+			return request%2 == 1
+		},
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	data, err := readAllWithTimeout(t, newTestRangeReader(httpServer.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, server.payload) {
+		t.Fatalf("read %d bytes that differ from the payload", len(data))
+	}
+}
+
+func TestRangeReaderResumesStalledConnections(t *testing.T) {
+	server := &rangeServer{
+		payload: newTestPayload(40 * 1024),
+		interrupt: func(request int) bool {
+			return request == 2
+		},
+		stall: true,
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	reader := newTestRangeReader(httpServer.URL)
+	reader.ReadTimeout = 100 * time.Millisecond
+	data, err := readAllWithTimeout(t, reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, server.payload) {
+		t.Fatalf("read %d bytes that differ from the payload", len(data))
+	}
+}
+
+func TestRangeReaderGivesUpAfterMaxRetries(t *testing.T) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer httpServer.Close()
+
+	reader := newTestRangeReader(httpServer.URL)
+	reader.MaxRetries = 2
+	_, err := readAllWithTimeout(t, reader)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected an HTTP status error, got %v", err)
+	}
+}
+
+func TestRangeReaderResolvesExpiredUrls(t *testing.T) {
+	server := &rangeServer{payload: newTestPayload(20 * 1024)}
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expired" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer httpServer.Close()
+
+	resolved := 0
+	reader := newTestRangeReader(httpServer.URL + "/expired")
+	reader.Resolve = func() (string, error) {
+		resolved++
+		return httpServer.URL + "/fresh", nil
+	}
+
+	data, err := readAllWithTimeout(t, reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(data, server.payload) {
+		t.Fatalf("read %d bytes that differ from the payload", len(data))
+	}
+	if resolved != 1 {
+		t.Errorf("expected the URL to be resolved once, got %d", resolved)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header string
+		end    int64
+		total  int64
+	}{
+		{"bytes 0-1023/4096", 1023, 4096},
+		{"bytes 1024-2047/*", 2047, -1},
+		{"bytes */4096", -1, -1},
+		{"", -1, -1},
+	}
+
+	for _, test := range tests {
+		end, total := parseContentRange(test.header)
+		if end != test.end || total != test.total {
+			t.Errorf("parseContentRange(%q) = %d, %d, expected %d, %d", test.header, end, total, test.end, test.total)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package codec
+
+import (
+	"strings"
+	"sync"
+)
+
+// testSink records the notifications of an AudioSink
+type testSink struct {
+	notifications []string
+	mutex         sync.Mutex
+}
+
+func (sink *testSink) record(notification string) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.notifications = append(sink.notifications, notification)
+}
+
+func (sink *testSink) events() string {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return strings.Join(sink.notifications, ",")
+}
+
+func (sink *testSink) OnBegin()    { sink.record("begin") }
+func (sink *testSink) OnFinished() { sink.record("finished") }
+func (sink *testSink) OnStopped()  { sink.record("stopped") }
+func (sink *testSink) OnFailed()   { sink.record("failed") }
+
+func (sink *testSink) SetSpeaking(speaking bool) {
+	if speaking {
+		sink.record("speaking")
+	} else {
+		sink.record("silent")
+	}
+}
+
+func (sink *testSink) SendOpusFrame(uint32, []byte) error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -10,6 +11,9 @@ import (
 
 var configValues map[Key]string
 
+// missingKeys are required keys that are not set in the environment
+var missingKeys []Key
+
 func init() {
 	configValues = make(map[Key]string)
 }
@@ -20,12 +24,30 @@ func loadKey(key Key, defaultValue string) {
 		if defaultValue != "" {
 			value = defaultValue
 		} else {
-			log.Fatalf("missing environment variable `%s`", key)
+			missingKeys = append(missingKeys, key)
 		}
 	}
 	configValues[key] = value
 }
 
+// CheckRequired returns an error if a required key is not set in the environment. Required keys are only
+// checked on request, so that packages can be tested without a configured environment.
+func CheckRequired() error {
+	if len(missingKeys) > 0 {
+		return fmt.Errorf("missing environment variable `%s`", missingKeys[0])
+	}
+	return nil
+}
+
+func loadOptionalKey(key Key) {
+	configValues[key] = os.Getenv(string(key))
+}
+
+// Set overrides the value of a key at runtime, for example to point a client at a local server
+func Set(key Key, value string) {
+	configValues[key] = value
+}
+
 //goland:noinspection GoUnusedExportedFunction
 func GetBool(key Key) bool {
 	return strings.ToLower(configValues[key]) == "true"
@@ -1,13 +1,61 @@
 package config
 
+import (
+	"path/filepath"
+	"strings"
+)
+
 type Key string
 
 const (
-	KeyAuthToken      = "YTB_AUTH_TOKEN"
-	KeyFfmpegLocation = "YTB_FFMPEG_LOCATION"
+	KeyAuthToken              = "YTB_AUTH_TOKEN"
+	KeyFfmpegLocation         = "YTB_FFMPEG_LOCATION"
+	KeyFfprobeLocation        = "YTB_FFPROBE_LOCATION"
+	KeyLibraryDirectory       = "YTB_LIBRARY_DIRECTORY"
+	KeyDataDirectory          = "YTB_DATA_DIRECTORY"
+	KeySfxDirectory           = "YTB_SFX_DIRECTORY"
+	KeyYtdlpTimeout           = "YTB_YTDLP_TIMEOUT"
+	KeyYtdlpMaxProcesses      = "YTB_YTDLP_MAX_PROCESSES"
+	KeyYtdlpDirectory         = "YTB_YTDLP_DIRECTORY"
+	KeyYtdlpVersion           = "YTB_YTDLP_VERSION"
+	KeyYtdlpDownloadUrl       = "YTB_YTDLP_DOWNLOAD_URL"
+	KeyYtdlpUpdateInterval    = "YTB_YTDLP_UPDATE_INTERVAL"
+	KeyPlaylistLimit          = "YTB_PLAYLIST_LIMIT"
+	KeyVideoListPreference    = "YTB_VIDEO_LIST_PREFERENCE"
+	KeyYouTubeBaseUrl         = "YTB_YOUTUBE_BASE_URL"
+	KeySponsorBlockUrl        = "YTB_SPONSORBLOCK_URL"
+	KeySponsorBlockCategories = "YTB_SPONSORBLOCK_CATEGORIES"
+	KeyLiveIdleTimeout        = "YTB_LIVE_IDLE_TIMEOUT"
 )
 
 func init() {
 	loadKey(KeyAuthToken, "")
 	loadKey(KeyFfmpegLocation, "")
+	loadKey(KeyFfprobeLocation, defaultFfprobeLocation())
+	loadOptionalKey(KeyLibraryDirectory)
+	loadKey(KeyDataDirectory, "data")
+	loadOptionalKey(KeySfxDirectory)
+	loadKey(KeyYtdlpTimeout, "60000")
+	loadKey(KeyYtdlpMaxProcesses, "4")
+	loadKey(KeyYtdlpDirectory, ".")
+	loadKey(KeyYtdlpVersion, "latest")
+	loadKey(KeyYtdlpDownloadUrl, "https://github.com/yt-dlp/yt-dlp/releases")
+	loadKey(KeyYtdlpUpdateInterval, "86400000")
+	loadKey(KeyPlaylistLimit, "1000")
+	loadKey(KeyVideoListPreference, "video")
+	loadKey(KeyYouTubeBaseUrl, "https://www.youtube.com")
+	loadKey(KeySponsorBlockUrl, "https://sponsor.ajay.app")
+	loadOptionalKey(KeySponsorBlockCategories)
+	loadKey(KeyLiveIdleTimeout, "300000")
+}
+
+// defaultFfprobeLocation assumes that ffprobe is installed next to ffmpeg
+func defaultFfprobeLocation() string {
+	ffmpegLocation := GetString(KeyFfmpegLocation)
+	dir, file := filepath.Split(ffmpegLocation)
+	idx := strings.LastIndex(strings.ToLower(file), "ffmpeg")
+	if idx < 0 {
+		return "ffprobe"
+	}
+	return dir + file[:idx] + "ffprobe" + file[idx+len("ffmpeg"):]
 }
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"go.uber.org/zap"
+	"strings"
+	"ytbot/discord"
+	"ytbot/ytapi"
+)
+
+func AutoplayCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	settings := GetGuildSettings(cmd.Message.GuildId)
+
+	switch arg := cmd.GetStringOrDefault(""); strings.ToLower(arg) {
+	case "":
+		if settings.Autoplay {
+			client.ReplyMessage(cmd.Message, EmojiNeutral+"Autoplay is **on**. Use `.autoplay off` to disable it")
+		} else {
+			client.ReplyMessage(cmd.Message, EmojiNeutral+"Autoplay is **off**. Use `.autoplay on` to enable it")
+		}
+	case "on":
+		settings.Autoplay = true
+		saveGuildSettings(cmd, client, settings, EmojiSuccess+"Autoplay enabled. Related videos are played when the queue is empty")
+	case "off":
+		settings.Autoplay = false
+		saveGuildSettings(cmd, client, settings, EmojiSuccess+"Autoplay disabled")
+	default:
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Usage: `.autoplay <on or off>`")
+	}
+}
+
+// findAutoplayItem picks a video related to the last played one, which was not played recently
+func findAutoplayItem(state *BotState) (ytapi.MediaItem, bool) {
+	if state.NowPlaying == nil || state.NowPlaying.Item.Type != ytapi.MediaTypeYouTube || len(state.NowPlaying.Item.Id) == 0 {
+		return ytapi.MediaItem{}, false
+	}
+
+	seed := state.NowPlaying.Item
+	related, err := ytapi.GetRelatedVideos(seed.Id)
+	if err != nil {
+		zap.S().Warnw("Failed to load related videos for autoplay", "videoId", seed.Id, "error", err)
+		return ytapi.MediaItem{}, false
+	}
+
+	for _, item := range related {
+		// Livestreams never end, so they would stop the autoplay
+		if !item.IsLive && !state.wasPlayedRecently(item.Id) {
+			return item, true
+		}
+	}
+
+	zap.S().Infow("No related video for autoplay was found", "videoId", seed.Id)
+	return ytapi.MediaItem{}, false
+}
+
+// extendMix adds the next videos of the mix that the last played item belongs to to the queue,
+// and returns whether any were added
+func extendMix(state *BotState) bool {
+	if state.NowPlaying == nil || len(state.NowPlaying.Item.MixId) == 0 {
+		return false
+	}
+
+	current := state.NowPlaying.Item
+	items, err := ytapi.GetMixItems(current.MixId, current.Id)
+	if err != nil {
+		zap.S().Warnw("Failed to continue mix", "mixId", current.MixId, "videoId", current.Id, "error", err)
+		return false
+	}
+
+	// The panel also lists the videos before the current one
+	for idx, item := range items {
+		if item.Id == current.Id {
+			items = items[idx+1:]
+			break
+		}
+	}
+
+	added := 0
+	for _, item := range items {
+		if !state.wasPlayedRecently(item.Id) {
+			state.Queue.Append(newQueueItem(item, current.Requester))
+			added++
+		}
+	}
+
+	zap.S().Debugw("Continued mix", "mixId", current.MixId, "added", added)
+	return added > 0
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ytbot/config"
+	"ytbot/discord"
+	"ytbot/ytapi"
+)
+
+// serveRelatedVideos starts an InnerTube server that recommends the video ids next to every video
+func serveRelatedVideos(t *testing.T, ids ...string) {
+	t.Helper()
+	renderers := make([]string, 0, len(ids))
+	for _, id := range ids {
+		renderers = append(renderers, fmt.Sprintf(`{"compactVideoRenderer": {"videoId": %q, "title": {"simpleText": %q}}}`, id, id))
+	}
+	response := `{"contents": {"twoColumnWatchNextResults": {"secondaryResults": {"secondaryResults": {"results": [` + strings.Join(renderers, ",") + `]}}}}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	config.Set(config.KeyYouTubeBaseUrl, server.URL)
+}
+
+func TestAutoplaySkipsUnplayableItems(t *testing.T) {
+	serveRelatedVideos(t, "unplayable1", "unplayable2", "playable123")
+	state := &BotState{NowPlaying: &NowPlaying{Item: newQueueItem(ytapi.MediaItem{Id: "seedVideo12"}, discord.User{})}}
+
+	// The seed stays the previous item while the picked items fail, so every failed item has to be skipped
+	for _, expected := range []string{"unplayable1", "unplayable2", "playable123"} {
+		item, ok := findAutoplayItem(state)
+		if !ok || item.Id != expected {
+			t.Fatalf("expected autoplay to pick %s, got %s (found: %t)", expected, item.Id, ok)
+		}
+		state.skipUnplayable(&NowPlaying{Item: newQueueItem(item, discord.User{}), Autoplay: true})
+	}
+
+	if item, ok := findAutoplayItem(state); ok {
+		t.Errorf("expected no autoplay item after all related videos failed, got %s", item.Id)
+	}
+	if len(state.History) != 3 || state.History[0].Status != HistoryFailed {
+		t.Errorf("expected the failed items in the history, got %+v", state.History)
+	}
+}
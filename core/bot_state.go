@@ -1,24 +1,135 @@
 package core
 
 import (
+	"go.uber.org/zap"
+	"sync"
+	"time"
 	"ytbot/codec"
 	"ytbot/discord"
 	"ytbot/ytapi"
 )
 
+// recentlyPlayedLimit is the number of played videos that autoplay avoids to repeat
+const recentlyPlayedLimit = 50
+
+// QueueItem is a media item in the queue, together with the user who requested it.
+// Items added by autoplay have no requester. The QueueId is assigned once the item is added to a Queue.
+type QueueItem struct {
+	ytapi.MediaItem
+	QueueId   uint64
+	Requester discord.User
+	AddedAt   time.Time
+}
+
+// BotState is the playback state of a guild. It is only changed on the command loop, goroutines that watch
+// the playback post their changes to it using runWhilePlaying.
 type BotState struct {
-	Queue   []ytapi.MediaItem
-	Encoder *codec.Encoder
+	Queue      Queue
+	Encoder    *codec.Encoder
+	Mixer      *codec.Mixer
+	NowPlaying *NowPlaying
+	History    []HistoryEntry
+
+	recentlyPlayed []string
+	// overlays is the number of playing sound effects. If overlaysJoined is set, the voice channel was
+	// joined to play them, and is left once they ended unless a media item started playing meanwhile.
+	overlays       int
+	overlaysJoined bool
+
+	guildId        string
+	voiceChannelId string
+	textChannelId  string
+	// interrupted is the playback that was restored from a snapshot, and can be continued using `.resume`
+	interrupted *playbackSnapshot
+	changes     chan interface{}
+	// mutex guards NowPlaying, Encoder, voiceChannelId, textChannelId and interrupted, which are read by persist.
+	// The command loop changes them while holding it, but reads them without locking.
+	mutex sync.Mutex
 }
 
 var botStates = make(map[string]*BotState)
+var botStatesMutex sync.Mutex
 
 func GetBotState(msg discord.Message) *BotState {
-	if botState, ok := botStates[msg.GuildId]; ok {
+	return getGuildBotState(msg.GuildId)
+}
+
+// getGuildBotState returns the state of the guild, creating it if needed. New states are saved whenever they change.
+func getGuildBotState(guildId string) *BotState {
+	botStatesMutex.Lock()
+	defer botStatesMutex.Unlock()
+
+	if botState, ok := botStates[guildId]; ok {
 		return botState
 	} else {
-		botState := &BotState{}
-		botStates[msg.GuildId] = botState
+		botState := &BotState{guildId: guildId, changes: make(chan interface{}, 1)}
+		botStates[guildId] = botState
+		go botState.persist(botState.Queue.Subscribe())
 		return botState
 	}
 }
+
+// markChanged requests saving a snapshot of the state, for changes other than the ones of the queue
+func (state *BotState) markChanged() {
+	select {
+	case state.changes <- nil:
+	default:
+	}
+}
+
+// runWhilePlaying runs the task on the command loop, unless the encoder was replaced or stopped by then.
+// Goroutines that watch the playback of an encoder use it to change the state.
+func (state *BotState) runWhilePlaying(encoder *codec.Encoder, task func()) {
+	runOnCommandLoop(func() {
+		stopped := false
+		select {
+		case <-encoder.Ended():
+			stopped = encoder.EndReason() == codec.EndStopped
+		default:
+		}
+
+		if state.Encoder != encoder || stopped {
+			zap.S().Debugln("Ignoring a playback change, because the encoder was replaced or stopped")
+			return
+		}
+		task()
+	})
+}
+
+// isPlaying checks whether the media item of NowPlaying is currently being played
+func (state *BotState) isPlaying() bool {
+	return state.NowPlaying != nil && state.Encoder != nil && state.Encoder.Running()
+}
+
+func newQueueItem(item ytapi.MediaItem, requester discord.User) QueueItem {
+	return QueueItem{MediaItem: item, Requester: requester, AddedAt: time.Now()}
+}
+
+// newQueueItems prepares media items for the queue, which were requested by the same user
+func newQueueItems(items []ytapi.MediaItem, requester discord.User) []QueueItem {
+	queueItems := make([]QueueItem, 0, len(items))
+	for _, item := range items {
+		queueItems = append(queueItems, newQueueItem(item, requester))
+	}
+	return queueItems
+}
+
+func (state *BotState) rememberPlayed(item ytapi.MediaItem) {
+	if len(item.Id) == 0 || (len(state.recentlyPlayed) > 0 && state.recentlyPlayed[len(state.recentlyPlayed)-1] == item.Id) {
+		return
+	}
+	state.recentlyPlayed = append(state.recentlyPlayed, item.Id)
+	if len(state.recentlyPlayed) > recentlyPlayedLimit {
+		state.recentlyPlayed = state.recentlyPlayed[len(state.recentlyPlayed)-recentlyPlayedLimit:]
+	}
+}
+
+// wasPlayedRecently checks whether a video with the id is among the recently played videos
+func (state *BotState) wasPlayedRecently(id string) bool {
+	for _, playedId := range state.recentlyPlayed {
+		if playedId == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+	"ytbot/codec"
+	"ytbot/discord"
+	"ytbot/ytapi"
+	"ytbot/ytdlp"
+)
+
+const (
+	// chapterProbeMinDuration is the minimum duration of items whose chapters are loaded during playback.
+	// Shorter videos rarely have chapters, so they are only loaded on request.
+	chapterProbeMinDuration = 10 * time.Minute
+	chapterWatchInterval    = time.Second
+	splitChaptersFlag       = "-split"
+)
+
+func ChaptersCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	withCurrentChapters(cmd, client, func(nowPlaying *NowPlaying, chapters []ytapi.Chapter) {
+		current := chapterAt(chapters, GetBotState(cmd.Message).Encoder.Position())
+
+		text := "__Chapters of `" + nowPlaying.Item.Name + "`__\n"
+		for i, chapter := range chapters {
+			line := "**" + strconv.Itoa(i+1) + ".** `" + formatDuration(chapter.Start) + "` " + chapter.Title
+			if i == current {
+				line = EmojiPlay + line
+			}
+			text += line + "\n"
+		}
+		client.ReplyMessage(cmd.Message, text)
+	})
+}
+
+func ChapterCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	index := cmd.GetIntOrDefault(0) - 1
+	withCurrentChapters(cmd, client, func(_ *NowPlaying, chapters []ytapi.Chapter) {
+		if index < 0 || index >= len(chapters) {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There is no chapter at that position")
+			return
+		}
+		seekToChapter(cmd, client, chapters[index])
+	})
+}
+
+func NextChapterCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	withCurrentChapters(cmd, client, func(_ *NowPlaying, chapters []ytapi.Chapter) {
+		next := chapterAt(chapters, GetBotState(cmd.Message).Encoder.Position()) + 1
+		if next >= len(chapters) {
+			client.ReplyMessage(cmd.Message, EmojiNeutral+"This is the last chapter")
+			return
+		}
+		seekToChapter(cmd, client, chapters[next])
+	})
+}
+
+func seekToChapter(cmd discord.CommandBuffer, client *discord.Client, chapter ytapi.Chapter) {
+	if seekPlayback(cmd, client, chapter.Start) {
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Skipped to chapter `"+chapter.Title+"`")
+	} else {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Nothing is playing")
+	}
+}
+
+// withCurrentChapters calls use on the command loop with the chapters of the playing media item. Chapters
+// that are not known yet are loaded off the command loop first, as yt-dlp takes a few seconds to load them.
+// It replies to the command instead if nothing is playing or there are no chapters.
+func withCurrentChapters(cmd discord.CommandBuffer, client *discord.Client, use func(nowPlaying *NowPlaying, chapters []ytapi.Chapter)) {
+	state := GetBotState(cmd.Message)
+	if !isPlaying(cmd, client) {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Nothing is playing")
+		return
+	}
+
+	nowPlaying := state.NowPlaying
+	chapters := nowPlaying.Chapters()
+	if item := nowPlaying.queueItem().MediaItem; len(chapters) == 0 && hasLoadableChapters(item) {
+		go func() {
+			nowPlaying.setChapters(loadChapters(item))
+			runOnCommandLoop(func() {
+				if !isPlaying(cmd, client) || state.NowPlaying != nowPlaying {
+					client.ReplyMessage(cmd.Message, EmojiFailed+"`"+item.Name+"` is not playing anymore")
+					return
+				}
+				useChapters(cmd, client, nowPlaying, nowPlaying.Chapters(), use)
+			})
+		}()
+		return
+	}
+	useChapters(cmd, client, nowPlaying, chapters, use)
+}
+
+func useChapters(cmd discord.CommandBuffer, client *discord.Client, nowPlaying *NowPlaying, chapters []ytapi.Chapter, use func(nowPlaying *NowPlaying, chapters []ytapi.Chapter)) {
+	if len(chapters) == 0 {
+		client.ReplyMessage(cmd.Message, EmojiNeutral+"`"+nowPlaying.Item.Name+"` has no chapters")
+		return
+	}
+	use(nowPlaying, chapters)
+}
+
+// isPlaying checks whether a media item of the bot state is playing in the voice channel
+func isPlaying(cmd discord.CommandBuffer, client *discord.Client) bool {
+	state := GetBotState(cmd.Message)
+	voiceClient := client.GetVoiceClient(cmd.Message.GuildId)
+	return state.NowPlaying != nil && state.Encoder != nil && voiceClient != nil && voiceClient.IsPlaying()
+}
+
+// watchChapters shows the title of the current chapter in the status message until the encoder is done.
+// The chapters of long media items are loaded first, if they are not known yet.
+func watchChapters(client *discord.Client, nowPlaying *NowPlaying, encoder *codec.Encoder) {
+	chapters := nowPlaying.Chapters()
+	if item := nowPlaying.queueItem().MediaItem; len(chapters) == 0 && hasLoadableChapters(item) && item.Duration >= chapterProbeMinDuration {
+		chapters = loadChapters(item)
+		nowPlaying.setChapters(chapters)
+	}
+	if len(chapters) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(chapterWatchInterval)
+	defer ticker.Stop()
+
+	current := -1
+	for {
+		if index := chapterAt(chapters, encoder.Position()); index != current && index >= 0 {
+			current = index
+			nowPlaying.SetChapter(client, chapters[index].Title)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-encoder.Done():
+			return
+		}
+	}
+}
+
+// hasLoadableChapters checks whether yt-dlp can find chapters for the item. Items that are a part of
+// a video, like a chapter that was split off, are not split any further.
+func hasLoadableChapters(item ytapi.MediaItem) bool {
+	return (item.Type == ytapi.MediaTypeYouTube || item.Type == ytapi.MediaTypeExtractor) && item.EndOffset == 0 && !item.IsLive
+}
+
+func loadChapters(item ytapi.MediaItem) []ytapi.Chapter {
+	chapters, err := ytdlp.GetChapters(context.Background(), item.Url)
+	if err != nil {
+		zap.S().Warnw("Failed to load chapters", "mediaUrl", item.Url, "error", err)
+		return nil
+	}
+	return chapters
+}
+
+// chapterAt returns the index of the chapter at the position, or -1 if the position is before the first chapter
+func chapterAt(chapters []ytapi.Chapter, position time.Duration) int {
+	index := -1
+	for i, chapter := range chapters {
+		if chapter.Start <= position {
+			index = i
+		}
+	}
+	return index
+}
+
+// splitChapters replaces every item that has chapters with one item per chapter, which only plays that chapter
+func splitChapters(items []ytapi.MediaItem) []ytapi.MediaItem {
+	result := make([]ytapi.MediaItem, 0, len(items))
+	for _, item := range items {
+		chapters := item.Chapters
+		if len(chapters) == 0 && hasLoadableChapters(item) {
+			chapters = loadChapters(item)
+		}
+		if len(chapters) == 0 {
+			result = append(result, item)
+			continue
+		}
+
+		for i, chapter := range chapters {
+			end := chapter.End
+			if end <= chapter.Start && i+1 < len(chapters) {
+				end = chapters[i+1].Start
+			} else if end <= chapter.Start {
+				end = item.Duration
+			}
+
+			part := item
+			part.Name = item.Name + " - " + chapter.Title
+			part.StartOffset = chapter.Start
+			part.EndOffset = end
+			part.Duration = end - chapter.Start
+			part.Chapters = nil
+			result = append(result, part)
+		}
+	}
+	return result
+}
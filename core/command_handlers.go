@@ -1,31 +1,66 @@
 package core
 
 import (
+	"errors"
 	"go.uber.org/zap"
 	"strconv"
 	"strings"
+	"ytbot/codec"
 	"ytbot/discord"
+	"ytbot/library"
 	"ytbot/ytapi"
+	"ytbot/ytdlp"
 )
 
 func init() {
 	RegisterCommand("ping", PingCommand)
 	RegisterCommand("play", PlayCommand)
+	RegisterCommand("search", SearchCommand)
 	RegisterCommand("skip", SkipCommand)
 	RegisterCommand("stop", StopCommand)
 	RegisterCommand("leave", StopCommand)
 	RegisterCommand("move", MoveCommand)
 	RegisterCommand("clear", ClearCommand)
 	RegisterCommand("remove", RemoveCommand)
+	RegisterCommand("shuffle", ShuffleCommand)
+	RegisterCommand("dedupe", DedupeCommand)
+	RegisterCommand("reverse", ReverseCommand)
+	RegisterCommand("skipto", SkipToCommand)
+	RegisterCommand("playnext", PlayNextCommand)
 	RegisterCommand("queue", QueueCommand)
+	RegisterCommand("radio", RadioCommand)
+	RegisterCommand("sfx", SfxCommand)
+	RegisterCommand("stats", StatsCommand)
+	RegisterCommand("autoplay", AutoplayCommand)
+	RegisterCommand("loop", LoopCommand)
+	RegisterCommand("history", HistoryCommand)
+	RegisterCommand("previous", PreviousCommand)
+	RegisterCommand("replay", ReplayCommand)
+	RegisterCommand("resume", ResumeCommand)
+	RegisterCommand("chapters", ChaptersCommand)
+	RegisterCommand("chapter", ChapterCommand)
+	RegisterCommand("next-chapter", NextChapterCommand)
 }
 
 func PingCommand(cmd discord.CommandBuffer, client *discord.Client) {
 	client.ReplyMessage(cmd.Message, "Pong! "+cmd.GetStringAll())
 }
 
+func StatsCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	stats := ytdlp.GetCacheStats()
+	client.ReplyMessage(cmd.Message, "__Statistics__\n"+
+		"**Stream URL cache**: "+strconv.Itoa(stats.Entries)+" entries, "+
+		strconv.FormatUint(stats.Hits, 10)+" hits, "+strconv.FormatUint(stats.Misses, 10)+" misses")
+}
+
 func PlayCommand(cmd discord.CommandBuffer, client *discord.Client) {
-	voiceState, inVoiceChannel := client.Guilds[cmd.Message.GuildId].VoiceStates[cmd.Message.Author.Id]
+	playQuery(cmd, client, false)
+}
+
+// playQuery loads the media items of the query given to a command, and adds them to the end of the queue,
+// or to its front if front is set
+func playQuery(cmd discord.CommandBuffer, client *discord.Client, front bool) {
+	voiceState, inVoiceChannel := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
 	if !inVoiceChannel {
 		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
 		return
@@ -34,51 +69,128 @@ func PlayCommand(cmd discord.CommandBuffer, client *discord.Client) {
 	statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Searching...")
 
 	query := strings.TrimSpace(cmd.GetStringAll())
-	if len(query) == 0 {
-		client.EditMessage(statusMsg, EmojiFailed+"A search query or YouTube link is required")
+	if len(query) == 0 && len(mediaAttachments(cmd.Message)) == 0 {
+		client.EditMessage(statusMsg, EmojiFailed+"A search query, YouTube link or media attachment is required")
 		return
 	}
 
-	items, err := ytapi.LoadMediaItems(query)
-	if err != nil {
-		client.EditMessage(statusMsg, EmojiFailed+"An error occurred while connecting to YouTube")
-		zap.S().Warnw("Failed to load media items from YouTube", "query", query, "error", err)
-		return
+	split := false
+	if strings.HasPrefix(query, splitChaptersFlag+" ") {
+		split = true
+		query = strings.TrimSpace(query[len(splitChaptersFlag):])
 	}
 
-	botState := GetBotState(cmd.Message)
-	for _, item := range items {
-		botState.Queue = append(botState.Queue, item)
+	if strings.HasPrefix(query, searchPickFlag+" ") {
+		query = strings.TrimSpace(query[len(searchPickFlag):])
+		if !isUrl(query) {
+			pickSearchResult(cmd, client, voiceState, statusMsg, query, front)
+			return
+		}
+	}
+
+	items, err := loadMediaItems(cmd.Message, query, func(loaded int, total int) {
+		progress := strconv.Itoa(loaded)
+		if total > 0 {
+			progress += "/" + strconv.Itoa(total)
+		}
+		client.EditMessage(statusMsg, EmojiLoading+"Loaded "+progress+"...")
+	})
+	if errors.Is(err, library.ErrNotConfigured) {
+		client.EditMessage(statusMsg, EmojiFailed+"There is no local music library configured")
+		return
+	} else if errors.Is(err, codec.ErrNoAudio) {
+		client.EditMessage(statusMsg, EmojiFailed+"That file does not contain any audio")
+		return
+	} else if errors.Is(err, ytapi.ErrLayoutChanged) {
+		client.EditMessage(statusMsg, EmojiFailed+"YouTube changed its layout, the bot needs to be updated")
+		zap.S().Errorw("Failed to parse YouTube response", "query", query, "error", err)
+		return
+	} else if err != nil {
+		client.EditMessage(statusMsg, EmojiFailed+"Failed to load the media: "+describeError(err))
+		zap.S().Warnw("Failed to load media items", "query", query, "error", err)
+		return
 	}
 
 	if len(items) == 0 {
 		client.EditMessage(statusMsg, EmojiFailed+"No results for `"+query+"`")
-	} else if len(items) == 1 {
-		client.EditMessage(statusMsg, EmojiSuccess+"Added `"+items[0].Name+"` to queue")
+		return
+	}
+
+	if split {
+		client.EditMessage(statusMsg, EmojiLoading+"Loading chapters...")
+		// yt-dlp is run for every item, which would block all other commands for a while
+		go func() {
+			items := splitChapters(items)
+			runOnCommandLoop(func() {
+				enqueueItems(cmd, client, voiceState, statusMsg, items, front)
+			})
+		}()
+		return
+	}
+
+	enqueueItems(cmd, client, voiceState, statusMsg, items, front)
+}
+
+// enqueueItems adds the items to the end of the queue, or to its front if front is set, and starts playback if idle
+func enqueueItems(cmd discord.CommandBuffer, client *discord.Client, voiceState discord.VoiceState, statusMsg discord.Message, items []ytapi.MediaItem, front bool) {
+	botState := GetBotState(cmd.Message)
+	target := "queue"
+	if front {
+		botState.Queue.Prepend(newQueueItems(items, cmd.Message.Author)...)
+		target = "the front of the queue"
+	} else {
+		botState.Queue.Append(newQueueItems(items, cmd.Message.Author)...)
+	}
+
+	if len(items) == 1 {
+		client.EditMessage(statusMsg, EmojiSuccess+"Added "+formatMediaItem(items[0])+" to "+target)
 	} else {
-		client.EditMessage(statusMsg, EmojiSuccess+"Added **"+strconv.Itoa(len(items))+" items** to queue")
+		client.EditMessage(statusMsg, EmojiSuccess+"Added **"+strconv.Itoa(len(items))+" items** to "+target)
 	}
 
 	voiceClient := client.GetVoiceClient(cmd.Message.GuildId)
 	if voiceClient == nil || !voiceClient.IsPlaying() {
 		zap.S().Debugln("Triggering playback because voice client is idle")
 		playNext(cmd, client, voiceState.GuildId, voiceState.ChannelId)
+	} else if botState.NowPlaying != nil && botState.NowPlaying.Item.IsLive {
+		client.ReplyMessage(cmd.Message, EmojiNeutral+"The current live stream does not end by itself, use `.skip` to continue with the queue")
 	}
 }
 
 func SkipCommand(cmd discord.CommandBuffer, client *discord.Client) {
-	if voiceState, ok := client.Guilds[cmd.Message.GuildId].VoiceStates[cmd.Message.Author.Id]; ok {
+	if voiceState, ok := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id); ok {
+		skipCurrent(cmd, client)
 		playNext(cmd, client, voiceState.GuildId, voiceState.ChannelId)
 	} else {
 		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
 	}
 }
 
+// skipCurrent records the current media item as skipped, and adds it to the end of the queue again if
+// the queue is looped. It does not stop playback, which happens once the next item starts.
+func skipCurrent(cmd discord.CommandBuffer, client *discord.Client) {
+	botState := GetBotState(cmd.Message)
+	voiceClient := client.GetVoiceClient(cmd.Message.GuildId)
+	if voiceClient == nil || !voiceClient.IsPlaying() {
+		return
+	}
+
+	botState.recordHistory(botState.NowPlaying, HistorySkipped)
+	if GetGuildSettings(cmd.Message.GuildId).Loop == LoopQueue {
+		requeue(botState)
+	}
+}
+
 func StopCommand(cmd discord.CommandBuffer, client *discord.Client) {
-	client.LeaveVoiceChannel(cmd.Message.GuildId)
 	botState := GetBotState(cmd.Message)
-	botState.Queue = nil
-	botState.Encoder.Stop()
+	botState.Queue.Clear()
+	botState.mutex.Lock()
+	botState.interrupted = nil
+	botState.mutex.Unlock()
+	if voiceClient := client.GetVoiceClient(cmd.Message.GuildId); voiceClient != nil && voiceClient.IsPlaying() {
+		botState.recordHistory(botState.NowPlaying, HistorySkipped)
+	}
+	stopPlayback(client, botState, cmd.Message.GuildId)
 	client.ReplyMessage(cmd.Message, EmojiStop+"Stopped playback and left the voice channel")
 }
 
@@ -86,50 +198,31 @@ func MoveCommand(cmd discord.CommandBuffer, client *discord.Client) {
 	oldIdx := cmd.GetIntOrDefault(-1) - 1
 	newIdx := cmd.GetIntOrDefault(-1) - 1
 
-	botState := GetBotState(cmd.Message)
-
-	if oldIdx < 0 || oldIdx >= len(botState.Queue) || newIdx < 0 || newIdx >= len(botState.Queue) {
+	if !GetBotState(cmd.Message).Queue.Move(oldIdx, newIdx) {
 		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item at that position")
 		return
 	}
 
-	newQueue := make([]ytapi.MediaItem, 0)
-	newQueue = append(newQueue, botState.Queue[:newIdx]...)
-	newQueue = append(newQueue, botState.Queue[oldIdx])
-	newQueue = append(newQueue, botState.Queue[newIdx:oldIdx]...)
-	newQueue = append(newQueue, botState.Queue[oldIdx+1:]...)
-
-	botState.Queue = newQueue
-
 	client.ReplyMessage(cmd.Message, EmojiSuccess+"Moved item #"+strconv.Itoa(oldIdx+1)+" to #"+strconv.Itoa(newIdx+1))
 }
 
 func ClearCommand(cmd discord.CommandBuffer, client *discord.Client) {
-	GetBotState(cmd.Message).Queue = nil
+	GetBotState(cmd.Message).Queue.Clear()
 	client.ReplyMessage(cmd.Message, EmojiSuccess+"Queue was cleared")
 }
 
-func RemoveCommand(cmd discord.CommandBuffer, client *discord.Client) {
-	index := cmd.GetInt() - 1
-	botState := GetBotState(cmd.Message)
-	if index < 0 || index >= len(botState.Queue) {
-		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item with that index")
-		return
-	}
-
-	item := botState.Queue[index]
-
-	botState.Queue = append(botState.Queue[:index], botState.Queue[index+1:]...)
-	client.ReplyMessage(cmd.Message, EmojiSuccess+"Item `"+item.Name+"` at position #"+strconv.Itoa(index+1)+" was removed.")
-}
-
 func QueueCommand(cmd discord.CommandBuffer, client *discord.Client) {
-	queue := GetBotState(cmd.Message).Queue
+	queue := GetBotState(cmd.Message).Queue.Items()
 	pageIdx := cmd.GetIntOrDefault(1) - 1
 	offset := pageIdx * 10
 
+	loopInfo := ""
+	if loop := GetGuildSettings(cmd.Message.GuildId).Loop; loop != LoopOff {
+		loopInfo = "\n" + EmojiLoop + describeLoop(loop)
+	}
+
 	if len(queue) == 0 {
-		client.ReplyMessage(cmd.Message, EmojiNeutral+"The queue is empty")
+		client.ReplyMessage(cmd.Message, EmojiNeutral+"The queue is empty"+loopInfo)
 	} else {
 		var lines []string
 
@@ -142,10 +235,10 @@ func QueueCommand(cmd discord.CommandBuffer, client *discord.Client) {
 		}
 
 		for idx, item := range queue[rangeMin:rangeMax] {
-			lines = append(lines, "**#"+strconv.Itoa(idx+1+offset)+"**: `"+item.Name+"`")
+			lines = append(lines, "**#"+strconv.Itoa(idx+1+offset)+"** `"+formatQueueId(item.QueueId)+"`: "+formatMediaItem(item.MediaItem))
 		}
 
-		client.ReplyMessage(cmd.Message, "__Playback queue (page "+strconv.Itoa(pageIdx+1)+")__\n"+strings.Join(lines, "\n"))
+		client.ReplyMessage(cmd.Message, "__Playback queue (page "+strconv.Itoa(pageIdx+1)+")__\n"+strings.Join(lines, "\n")+loopInfo)
 	}
 
 }
@@ -10,6 +10,9 @@ type CommandHandler = func(cmd discord.CommandBuffer, client *discord.Client)
 
 var commands = make(map[string]CommandHandler)
 
+// tasks are run on the command loop, between commands
+var tasks = make(chan func(), 25)
+
 func RegisterCommand(name string, handler CommandHandler) {
 	commands[strings.ToLower(name)] = handler
 }
@@ -24,3 +27,27 @@ func HandleCommand(cmd discord.CommandBuffer, client *discord.Client) {
 		client.ReplyMessage(cmd.Message, "Unknown command `"+name+"`")
 	}
 }
+
+// RunCommandLoop handles the commands of the client and the tasks posted with runOnCommandLoop one at a time,
+// until the command channel is closed
+func RunCommandLoop(client *discord.Client) {
+	for {
+		select {
+		case cmd, ok := <-client.Commands:
+			if !ok {
+				return
+			}
+			HandleCommand(cmd, client)
+		case task := <-tasks:
+			task()
+		}
+	}
+}
+
+// runOnCommandLoop runs the task on the command loop, so that it does not race with command handlers.
+// It does not wait for the task, so it may also be called from the command loop to run a task after the current one.
+func runOnCommandLoop(task func()) {
+	go func() {
+		tasks <- task
+	}()
+}
@@ -7,4 +7,8 @@ const (
 	EmojiSuccess = ":green_circle:  "
 	EmojiPlay    = ":arrow_forward:  "
 	EmojiStop    = ":stop_button:  "
+	EmojiRadio   = ":radio:  "
+	EmojiChapter = ":bookmark:  "
+	EmojiSkip    = ":fast_forward:  "
+	EmojiLoop    = ":repeat:  "
 )
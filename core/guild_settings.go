@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sync"
+	"ytbot/config"
+)
+
+// GuildSettings stores the per-guild configuration, which is persisted in the data directory
+type GuildSettings struct {
+	Stations map[string]string `json:"stations"`
+	Autoplay bool              `json:"autoplay"`
+	Loop     LoopMode          `json:"loop"`
+
+	guildId string
+}
+
+var guildSettings = make(map[string]*GuildSettings)
+var guildSettingsMutex sync.Mutex
+
+func GetGuildSettings(guildId string) *GuildSettings {
+	guildSettingsMutex.Lock()
+	defer guildSettingsMutex.Unlock()
+
+	if settings, ok := guildSettings[guildId]; ok {
+		return settings
+	}
+
+	settings := loadGuildSettings(guildId)
+	guildSettings[guildId] = settings
+	return settings
+}
+
+func (settings *GuildSettings) Save() error {
+	guildSettingsMutex.Lock()
+	defer guildSettingsMutex.Unlock()
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(guildSettingsPath(settings.guildId), data)
+}
+
+// writeFileAtomic replaces the file at path, so that it is never left partially written
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	err = os.WriteFile(tempPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func loadGuildSettings(guildId string) *GuildSettings {
+	settings := &GuildSettings{guildId: guildId}
+
+	data, err := os.ReadFile(guildSettingsPath(guildId))
+	if err == nil {
+		err = json.Unmarshal(data, settings)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		zap.S().Warnw("Failed to load guild settings, using defaults", "guildId", guildId, "error", err)
+	}
+
+	if settings.Stations == nil {
+		settings.Stations = make(map[string]string)
+	}
+	if len(settings.Loop) == 0 {
+		settings.Loop = LoopOff
+	}
+	return settings
+}
+
+func guildSettingsPath(guildId string) string {
+	return filepath.Join(config.GetString(config.KeyDataDirectory), "guilds", guildId+".json")
+}
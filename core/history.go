@@ -0,0 +1,135 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"ytbot/discord"
+	"ytbot/ytapi"
+)
+
+// historyLimit is the number of played media items that are kept in the history of a guild
+const historyLimit = 100
+
+// HistoryStatus describes how playback of a media item ended
+type HistoryStatus string
+
+const (
+	HistoryFinished HistoryStatus = "finished"
+	HistorySkipped  HistoryStatus = "skipped"
+	HistoryFailed   HistoryStatus = "failed"
+)
+
+// HistoryEntry is a media item that was played, together with the time and the way that its playback ended
+type HistoryEntry struct {
+	Item      QueueItem
+	StartedAt time.Time
+	EndedAt   time.Time
+	Status    HistoryStatus
+}
+
+// recordHistory adds the media item of nowPlaying to the history, unless its end was already recorded
+func (state *BotState) recordHistory(nowPlaying *NowPlaying, status HistoryStatus) {
+	if nowPlaying == nil || !nowPlaying.end() {
+		return
+	}
+
+	state.History = append(state.History, HistoryEntry{
+		Item:      nowPlaying.queueItem(),
+		StartedAt: nowPlaying.StartedAt,
+		EndedAt:   time.Now(),
+		Status:    status,
+	})
+	if len(state.History) > historyLimit {
+		state.History = state.History[len(state.History)-historyLimit:]
+	}
+}
+
+// historyEntry returns the entry at the 1-based position, counting from the most recently played item
+func (state *BotState) historyEntry(position int) (HistoryEntry, bool) {
+	if position < 1 || position > len(state.History) {
+		return HistoryEntry{}, false
+	}
+	return state.History[len(state.History)-position], true
+}
+
+func HistoryCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	state := GetBotState(cmd.Message)
+	history := state.History
+	pageIdx := cmd.GetIntOrDefault(1) - 1
+	offset := pageIdx * 10
+
+	if len(history) == 0 {
+		client.ReplyMessage(cmd.Message, EmojiNeutral+"Nothing was played yet")
+		return
+	}
+
+	rangeMin := max(offset, 0)
+	rangeMax := min(offset+10, len(history))
+	if rangeMax <= 0 || rangeMin >= len(history) {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no page "+strconv.Itoa(pageIdx+1))
+		return
+	}
+
+	var lines []string
+	for position := rangeMin + 1; position <= rangeMax; position++ {
+		entry, _ := state.historyEntry(position)
+		lines = append(lines, "**#"+strconv.Itoa(position)+"**: "+formatMediaItem(entry.Item.MediaItem)+
+			" requested by "+formatRequester(entry.Item)+", "+string(entry.Status)+" <t:"+strconv.FormatInt(entry.EndedAt.Unix(), 10)+":R>")
+	}
+
+	client.ReplyMessage(cmd.Message, "__Playback history (page "+strconv.Itoa(pageIdx+1)+")__\n"+strings.Join(lines, "\n"))
+}
+
+// PreviousCommand plays the most recently played media item again, and continues with the current one afterwards
+func PreviousCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	voiceState, ok := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
+		return
+	}
+
+	state := GetBotState(cmd.Message)
+	previous, ok := state.historyEntry(1)
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no previous item")
+		return
+	}
+	state.History = state.History[:len(state.History)-1]
+
+	items := []QueueItem{previous.Item}
+	voiceClient := client.GetVoiceClient(cmd.Message.GuildId)
+	if state.NowPlaying != nil && voiceClient != nil && voiceClient.IsPlaying() {
+		// The current item is played again after the previous one, so it is not recorded as skipped
+		state.NowPlaying.end()
+		items = append(items, state.NowPlaying.queueItem())
+	}
+	state.Queue.Prepend(items...)
+
+	playNext(cmd, client, voiceState.GuildId, voiceState.ChannelId)
+}
+
+// ReplayCommand adds an item of the history to the end of the queue
+func ReplayCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	voiceState, ok := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
+		return
+	}
+
+	entry, ok := GetBotState(cmd.Message).historyEntry(cmd.GetIntOrDefault(-1))
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item at that position of the history")
+		return
+	}
+
+	statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Adding `"+entry.Item.Name+"` to the queue...")
+	enqueueItems(cmd, client, voiceState, statusMsg, []ytapi.MediaItem{entry.Item.MediaItem}, false)
+}
+
+func formatRequester(item QueueItem) string {
+	if len(item.Requester.Id) == 0 {
+		return "autoplay"
+	}
+	return "**" + item.Requester.Username + "**"
+}
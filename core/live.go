@@ -0,0 +1,50 @@
+package core
+
+import (
+	"go.uber.org/zap"
+	"time"
+	"ytbot/codec"
+	"ytbot/config"
+	"ytbot/discord"
+)
+
+const liveListenerCheckInterval = 10 * time.Second
+
+// watchLiveListeners stops a live stream and leaves the voice channel once nobody listened to it for the
+// configured idle timeout, because the stream would never end by itself
+func watchLiveListeners(client *discord.Client, state *BotState, guildId string, nowPlaying *NowPlaying, encoder *codec.Encoder) {
+	timeout := config.GetMilliseconds(config.KeyLiveIdleTimeout)
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(liveListenerCheckInterval)
+	defer ticker.Stop()
+
+	var idleSince time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-encoder.Done():
+			return
+		}
+
+		if client.CountListeners(guildId) > 0 {
+			idleSince = time.Time{}
+			continue
+		}
+		if idleSince.IsZero() {
+			idleSince = time.Now()
+		}
+
+		if time.Since(idleSince) >= timeout {
+			zap.S().Infow("Stopping live stream without listeners", "guildId", guildId, "mediaName", nowPlaying.Item.Name)
+			client.ReplyMessage(nowPlaying.Message, EmojiStop+"Stopped the live stream and left the voice channel because nobody was listening")
+			state.runWhilePlaying(encoder, func() {
+				state.recordHistory(nowPlaying, HistorySkipped)
+				stopPlayback(client, state, guildId)
+			})
+			return
+		}
+	}
+}
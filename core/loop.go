@@ -0,0 +1,74 @@
+package core
+
+import (
+	"go.uber.org/zap"
+	"strings"
+	"ytbot/discord"
+)
+
+// LoopMode decides what is played after a media item finished
+type LoopMode string
+
+const (
+	LoopOff LoopMode = "off"
+	// LoopTrack replays the current media item until it is skipped
+	LoopTrack LoopMode = "track"
+	// LoopQueue appends finished media items to the end of the queue again
+	LoopQueue LoopMode = "queue"
+)
+
+func LoopCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	settings := GetGuildSettings(cmd.Message.GuildId)
+
+	switch mode := LoopMode(strings.ToLower(cmd.GetStringOrDefault(""))); mode {
+	case "":
+		client.ReplyMessage(cmd.Message, EmojiNeutral+"Loop mode is **"+string(settings.Loop)+"**. Use `.loop <track, queue or off>` to change it")
+	case LoopOff, LoopTrack, LoopQueue:
+		settings.Loop = mode
+		if nowPlaying := GetBotState(cmd.Message).NowPlaying; nowPlaying != nil {
+			nowPlaying.SetLoop(client, mode)
+		}
+		saveGuildSettings(cmd, client, settings, EmojiSuccess+describeLoop(mode))
+	default:
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Usage: `.loop <track, queue or off>`")
+	}
+}
+
+// playFinished continues playback after the current media item finished, repeating it depending on the loop mode
+func playFinished(cmd discord.CommandBuffer, client *discord.Client, guildId string, channelId string) {
+	state := GetBotState(cmd.Message)
+	nowPlaying := state.NowPlaying
+	if nowPlaying == nil {
+		playNext(cmd, client, guildId, channelId)
+		return
+	}
+
+	switch GetGuildSettings(guildId).Loop {
+	case LoopTrack:
+		zap.S().Debugw("Looping current media item", "mediaName", nowPlaying.Item.Name)
+		repeated := nowPlaying.repeat()
+		startPlayback(cmd, client, guildId, channelId, repeated, repeated.Item.StartOffset, 0)
+		return
+	case LoopQueue:
+		requeue(state)
+	}
+	playNext(cmd, client, guildId, channelId)
+}
+
+// requeue appends the current media item to the end of the queue
+func requeue(state *BotState) {
+	if state.NowPlaying != nil {
+		state.Queue.Append(state.NowPlaying.queueItem())
+	}
+}
+
+func describeLoop(mode LoopMode) string {
+	switch mode {
+	case LoopTrack:
+		return "Looping the current track"
+	case LoopQueue:
+		return "Looping the queue"
+	default:
+		return "Looping disabled"
+	}
+}
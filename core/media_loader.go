@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"net/url"
+	"strings"
+	"ytbot/discord"
+	"ytbot/library"
+	"ytbot/radio"
+	"ytbot/ytapi"
+	"ytbot/ytdlp"
+)
+
+const localFilePrefix = "file:"
+
+// errNotResolved is returned by a mediaResolver that does not handle the query, so the next one is tried
+var errNotResolved = errors.New("the query was not resolved")
+
+// mediaResolver loads the media items for a `.play` command, or returns errNotResolved.
+// Resolvers that load long lists report their progress to onProgress.
+type mediaResolver func(message discord.Message, query string, onProgress ytapi.ProgressFunc) ([]ytapi.MediaItem, error)
+
+// mediaResolvers are tried in order. Attachments of the message take precedence, followed by files from
+// the local library, YouTube links and searches, internet radio streams, and finally any other site
+// that yt-dlp supports.
+var mediaResolvers = []mediaResolver{
+	resolveAttachments,
+	resolveLibraryFiles,
+	resolveYouTube,
+	resolveRadioStream,
+	resolveExtractor,
+}
+
+func loadMediaItems(message discord.Message, query string, onProgress ytapi.ProgressFunc) ([]ytapi.MediaItem, error) {
+	for _, resolver := range mediaResolvers {
+		items, err := resolver(message, query, onProgress)
+		if !errors.Is(err, errNotResolved) {
+			return items, err
+		}
+	}
+	return nil, ytapi.ErrUnsupportedQuery
+}
+
+func resolveAttachments(message discord.Message, _ string, _ ytapi.ProgressFunc) ([]ytapi.MediaItem, error) {
+	attachments := mediaAttachments(message)
+	if len(attachments) == 0 {
+		return nil, errNotResolved
+	}
+
+	items := make([]ytapi.MediaItem, 0)
+	for _, attachment := range attachments {
+		item, err := library.LoadMediaItem(attachment.Url, attachment.Filename)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func resolveLibraryFiles(_ discord.Message, query string, _ ytapi.ProgressFunc) ([]ytapi.MediaItem, error) {
+	if !strings.HasPrefix(strings.ToLower(query), localFilePrefix) {
+		return nil, errNotResolved
+	}
+
+	paths, err := library.Find(query[len(localFilePrefix):])
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ytapi.MediaItem, 0)
+	for _, path := range paths {
+		item, err := library.LoadMediaItem(path, fileDisplayName(path))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func resolveYouTube(_ discord.Message, query string, onProgress ytapi.ProgressFunc) ([]ytapi.MediaItem, error) {
+	items, err := ytapi.LoadMediaItems(query, onProgress)
+	if errors.Is(err, ytapi.ErrUnsupportedQuery) {
+		return nil, errNotResolved
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(items) == 1 && !items[0].HasMetadata() {
+		// The scraper could not find the details of a single video, so yt-dlp has to fill them in
+		err = ytdlp.FillMetadata(context.Background(), &items[0])
+		if err != nil {
+			zap.S().Warnw("Failed to load media metadata using yt-dlp", "mediaUrl", items[0].Url, "error", err)
+		}
+	}
+
+	return items, nil
+}
+
+func resolveRadioStream(_ discord.Message, query string, _ ytapi.ProgressFunc) ([]ytapi.MediaItem, error) {
+	if !isUrl(query) {
+		return nil, errNotResolved
+	}
+
+	item, err := radio.LoadMediaItem(query)
+	if err != nil {
+		zap.S().Debugw("URL is not an internet radio stream", "url", query, "error", err)
+		return nil, errNotResolved
+	}
+	return []ytapi.MediaItem{item}, nil
+}
+
+func resolveExtractor(_ discord.Message, query string, _ ytapi.ProgressFunc) ([]ytapi.MediaItem, error) {
+	if !isUrl(query) {
+		return nil, errNotResolved
+	}
+
+	return ytdlp.LoadMediaItems(context.Background(), query)
+}
+
+func isUrl(query string) bool {
+	urlVal, err := url.Parse(query)
+	return err == nil && len(urlVal.Hostname()) > 0
+}
+
+func mediaAttachments(message discord.Message) []discord.Attachment {
+	attachments := make([]discord.Attachment, 0)
+	for _, attachment := range message.Attachments {
+		contentType := strings.ToLower(attachment.ContentType)
+		if strings.HasPrefix(contentType, "audio/") || strings.HasPrefix(contentType, "video/") || library.IsSupportedFile(attachment.Filename) {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments
+}
+
+func fileDisplayName(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	name := path[strings.LastIndex(path, "/")+1:]
+	if idx := strings.LastIndex(name, "."); idx > 0 {
+		name = name[:idx]
+	}
+	return name
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"ytbot/discord"
+	"ytbot/sponsorblock"
+	"ytbot/ytapi"
+)
+
+// NowPlaying tracks the status message of the currently playing media item
+type NowPlaying struct {
+	Item      QueueItem
+	Message   discord.Message
+	Autoplay  bool
+	StartedAt time.Time
+
+	streamTitle string
+	chapter     string
+	loop        LoopMode
+	ended       bool
+	mutex       sync.Mutex
+
+	segments          []sponsorblock.Segment
+	segmentsLoaded    bool
+	skipped           time.Duration
+	skippedCategories []string
+}
+
+// SetStreamTitle updates the current song title of an internet radio stream and edits the status message
+func (np *NowPlaying) SetStreamTitle(client *discord.Client, title string) {
+	np.mutex.Lock()
+	np.streamTitle = title
+	np.mutex.Unlock()
+
+	client.EditMessage(np.Message, np.String())
+}
+
+// SetChapter updates the title of the current chapter and edits the status message
+func (np *NowPlaying) SetChapter(client *discord.Client, title string) {
+	np.mutex.Lock()
+	np.chapter = title
+	np.mutex.Unlock()
+
+	client.EditMessage(np.Message, np.String())
+}
+
+// SetLoop updates the loop mode shown in the status message and edits it
+func (np *NowPlaying) SetLoop(client *discord.Client, mode LoopMode) {
+	np.setLoop(mode)
+	client.EditMessage(np.Message, np.String())
+}
+
+func (np *NowPlaying) setLoop(mode LoopMode) {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	np.loop = mode
+}
+
+// repeat returns a new NowPlaying to play the same media item again, keeping the loaded SponsorBlock segments
+func (np *NowPlaying) repeat() *NowPlaying {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	return &NowPlaying{
+		Item:           np.Item,
+		Message:        np.Message,
+		Autoplay:       np.Autoplay,
+		segments:       np.segments,
+		segmentsLoaded: np.segmentsLoaded,
+	}
+}
+
+// end marks the playback of the media item as ended, and returns false if it was already marked before
+func (np *NowPlaying) end() bool {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	if np.ended {
+		return false
+	}
+	np.ended = true
+	return true
+}
+
+// queueItem returns a copy of Item. Item is changed while playing, once the chapters are loaded.
+func (np *NowPlaying) queueItem() QueueItem {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	return np.Item
+}
+
+// setLive marks the media item as a live stream, once its stream URL turned out to be one
+func (np *NowPlaying) setLive() {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	np.Item.IsLive = true
+}
+
+// Chapters returns the chapters of the media item, which may be loaded after playback started
+func (np *NowPlaying) Chapters() []ytapi.Chapter {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	return np.Item.Chapters
+}
+
+func (np *NowPlaying) setChapters(chapters []ytapi.Chapter) {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	np.Item.Chapters = chapters
+}
+
+func (np *NowPlaying) sponsorSegments() ([]sponsorblock.Segment, bool) {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	return np.segments, np.segmentsLoaded
+}
+
+func (np *NowPlaying) setSponsorSegments(segments []sponsorblock.Segment) {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+	np.segments = segments
+	np.segmentsLoaded = true
+}
+
+// addSkipped adds to the time that was skipped using SponsorBlock, which is shown in the status message
+func (np *NowPlaying) addSkipped(duration time.Duration, category string) {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+
+	np.skipped += duration
+	for _, skippedCategory := range np.skippedCategories {
+		if skippedCategory == category {
+			return
+		}
+	}
+	np.skippedCategories = append(np.skippedCategories, category)
+}
+
+func (np *NowPlaying) String() string {
+	np.mutex.Lock()
+	defer np.mutex.Unlock()
+
+	text := EmojiPlay + "Now playing: " + formatMediaItem(np.Item.MediaItem)
+	if len(np.Item.Uploader) > 0 {
+		text += " by **" + np.Item.Uploader + "**"
+	}
+	text += "."
+	if np.Autoplay {
+		text += " _(autoplay)_"
+	}
+	if len(np.streamTitle) > 0 {
+		text += "\n" + EmojiRadio + "`" + np.streamTitle + "`"
+	}
+	if len(np.chapter) > 0 {
+		text += "\n" + EmojiChapter + "`" + np.chapter + "`"
+	}
+	if np.loop == LoopTrack || np.loop == LoopQueue {
+		text += "\n" + EmojiLoop + describeLoop(np.loop)
+	}
+	if np.skipped > 0 {
+		text += "\n" + EmojiSkip + "Skipped `" + formatDuration(np.skipped) + "` (" + strings.Join(np.skippedCategories, ", ") + ") using SponsorBlock"
+	}
+	return text
+}
@@ -1,34 +1,76 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"go.uber.org/zap"
+	"time"
 	"ytbot/codec"
 	"ytbot/discord"
+	"ytbot/ytapi"
 	"ytbot/ytdlp"
 )
 
+const (
+	maxResumeAttempts = 3
+	// liveResumeResetAfter is the time after which an interrupted live stream counts as recovered, as live
+	// streams never end and would otherwise run out of resume attempts eventually
+	liveResumeResetAfter = 10 * time.Minute
+)
+
 func playNext(cmd discord.CommandBuffer, client *discord.Client, guildId string, channelId string) {
 	state := GetBotState(cmd.Message)
-	if len(state.Queue) == 0 {
+	nextSong, ok := state.Queue.Pop()
+	if !ok && extendMix(state) {
+		nextSong, ok = state.Queue.Pop()
+	}
+	if !ok {
+		if GetGuildSettings(guildId).Autoplay {
+			if item, ok := findAutoplayItem(state); ok {
+				statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Preparing to autoplay `"+item.Name+"`...")
+				startPlayback(cmd, client, guildId, channelId, &NowPlaying{Item: newQueueItem(item, discord.User{}), Message: statusMsg, Autoplay: true}, 0, 0)
+				return
+			}
+		}
+
 		zap.S().Debugln("Playback queue is empty, exiting from playNext()")
 		return
 	}
 
-	nextSong := state.Queue[0]
-	state.Queue = state.Queue[1:]
-
 	statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Preparing to play `"+nextSong.Name+"`...")
+	startPlayback(cmd, client, guildId, channelId, &NowPlaying{Item: nextSong, Message: statusMsg}, nextSong.StartOffset, 0)
+}
+
+// startPlayback plays the media item of nowPlaying from the offset, and reports its status by editing the
+// message of nowPlaying. Interrupted playback is resumed using increasing attempts.
+func startPlayback(cmd discord.CommandBuffer, client *discord.Client, guildId string, channelId string, nowPlaying *NowPlaying, offset time.Duration, attempt int) {
+	state := GetBotState(cmd.Message)
+	item := nowPlaying.queueItem().MediaItem
+	statusMsg := nowPlaying.Message
 
-	zap.S().Debugw("Fetching YouTube streaming URL", "mediaName", nextSong.Name, "mediaUrl", nextSong.Url)
-	url, err := ytdlp.GetStreamUrl(nextSong.Url)
+	stream, err := resolveStream(item, attempt > 0)
 	if err != nil {
-		zap.S().Errorw("Failed to get YouTube streaming URL", "mediaName", nextSong.Name, "error", err)
-		client.EditMessage(statusMsg, EmojiFailed+"Failed to get YouTube stream URL")
+		zap.S().Errorw("Failed to get streaming URL", "mediaName", item.Name, "error", err)
+		client.EditMessage(statusMsg, EmojiFailed+"Failed to get stream URL: "+describeError(err))
+		state.skipUnplayable(nowPlaying)
+		runOnCommandLoop(func() {
+			playNext(cmd, client, guildId, channelId)
+		})
 		return
 	}
 
-	zap.S().Debugln("Joining voice channel")
-	voiceClient, err := client.JoinVoiceChannel(guildId, channelId)
+	url := stream.Url
+	if !item.IsLive && stream.IsLive {
+		zap.S().Infow("Media item resolved to a live stream", "mediaName", item.Name)
+		item.IsLive = true
+		nowPlaying.setLive()
+	}
+	if item.IsLive {
+		// Live streams always continue at the live edge
+		offset = 0
+	}
+
+	voiceClient, err := joinVoiceChannel(client, guildId, channelId)
 	if err != nil {
 		zap.S().Errorw("Failed to join voice channel", "guildId", guildId, "channelId", channelId, "error", err)
 		client.EditMessage(statusMsg, EmojiFailed+"Failed to join voice channel")
@@ -46,43 +88,237 @@ func playNext(cmd discord.CommandBuffer, client *discord.Client, guildId string,
 		}
 	}
 
-	if !voiceClient.IsReady() {
-		zap.S().Debugln("Waiting for voice client to become ready")
-		for event := range voiceClient.Events {
-			if event == discord.VoiceEventReady {
-				break
-			}
-		}
+	mixer, err := getMixer(state, voiceClient)
+	if err != nil {
+		zap.S().Errorw("Failed to start audio mixer", "guildId", guildId, "error", err)
+		client.EditMessage(statusMsg, EmojiFailed+"Failed to start audio stream")
+		return
 	}
 
-	zap.S().Debugw("Starting encoder for a media item", "mediaName", nextSong.Name)
-	state.Encoder = codec.NewEncoder(url, voiceClient.VoiceStream)
-	err = state.Encoder.Start()
+	state.mutex.Lock()
+	state.NowPlaying = nowPlaying
+	state.voiceChannelId = channelId
+	state.textChannelId = cmd.Message.ChannelId
+	state.interrupted = nil
+	state.mutex.Unlock()
+	// Media items keep the voice channel joined, even if a sound effect joined it
+	state.overlaysJoined = false
+	if nowPlaying.StartedAt.IsZero() {
+		nowPlaying.StartedAt = time.Now()
+	}
+	nowPlaying.setLoop(GetGuildSettings(guildId).Loop)
+	if attempt == 0 {
+		state.rememberPlayed(item)
+	}
+
+	zap.S().Debugw("Starting encoder for a media item", "mediaName", item.Name, "offset", offset)
+	source := codec.Source{
+		Url:         url,
+		StartOffset: offset,
+		EndOffset:   item.EndOffset,
+		Resolve: func() (string, error) {
+			stream, err := resolveStream(item, true)
+			return stream.Url, err
+		},
+		Stream: item.Type == ytapi.MediaTypeStream,
+		Hls:    codec.IsHlsUrl(url),
+		OnStreamTitle: func(title string) {
+			zap.S().Debugw("Stream title changed", "mediaName", item.Name, "title", title)
+			nowPlaying.SetStreamTitle(client, title)
+		},
+	}
+	encoder := codec.NewEncoder(source, mixer)
+	err = encoder.Start()
 	if err != nil {
-		zap.S().Errorw("Failed to start encoder for a media item", "mediaName", nextSong.Name)
-		client.EditMessage(statusMsg, EmojiFailed+"Failed to start audio stream")
+		zap.S().Errorw("Failed to start encoder for a media item", "mediaName", item.Name, "error", err)
+		client.EditMessage(statusMsg, EmojiFailed+"Failed to start audio stream: "+describeError(err))
 		return
 	}
+	state.mutex.Lock()
+	state.Encoder = encoder
+	state.mutex.Unlock()
+
+	go watchChapters(client, nowPlaying, encoder)
+	go skipSegments(cmd, client, guildId, channelId, nowPlaying, encoder)
+	if item.IsLive {
+		go watchLiveListeners(client, state, guildId, nowPlaying, encoder)
+	}
 
-	client.EditMessage(statusMsg, EmojiPlay+"Now playing: `"+nextSong.Name+"`.")
-	zap.S().Infow("A new media item started playing", "guildId", guildId, "mediaName", nextSong.Name)
+	state.markChanged()
+
+	if attempt == 0 {
+		client.EditMessage(statusMsg, nowPlaying.String())
+		zap.S().Infow("A new media item started playing", "guildId", guildId, "mediaName", item.Name)
+	} else {
+		zap.S().Infow("A media item resumed playing", "guildId", guildId, "mediaName", item.Name, "offset", offset, "attempt", attempt)
+	}
 
 	go func() {
 		zap.S().Debugln("Waiting for playback to finish")
-		for event := range voiceClient.Events {
-			if event == discord.VoiceEventFinished {
-				zap.S().Debugln("Playback finished gracefully, starting next one")
-				go playNext(cmd, client, guildId, channelId)
-				return
-			} else if event == discord.VoiceEventError {
-				zap.S().Warnw("Playback finished with error, sending error message", "mediaName", nextSong.Name)
-				client.ReplyMessage(statusMsg, EmojiFailed+"Something went wrong during playback")
-				client.LeaveVoiceChannel(cmd.Message.GuildId)
-				return
-			} else if event == discord.VoiceEventStopped {
-				zap.S().Debugln("Playback was stopped, not starting next one")
+		for {
+			select {
+			case <-encoder.Ended():
+				if encoder.EndReason() != codec.EndStopped {
+					// The events of this encoder must not be mistaken for the ones of the next item
+					drainVoiceEvents(voiceClient)
+				}
+
+				// The state is only changed on the command loop
+				switch encoder.EndReason() {
+				case codec.EndFinished:
+					zap.S().Debugln("Playback finished gracefully, starting next one")
+					state.runWhilePlaying(encoder, func() {
+						state.recordHistory(nowPlaying, HistoryFinished)
+						playFinished(cmd, client, guildId, channelId)
+					})
+				case codec.EndFailed:
+					encoderErr := encoder.Err()
+					if codec.IsRecoverable(encoderErr) && attempt < maxResumeAttempts {
+						position := encoder.Position()
+						nextAttempt := attempt + 1
+						if item.IsLive && position >= liveResumeResetAfter {
+							nextAttempt = 1
+						}
+						zap.S().Infow("Playback was interrupted, resuming", "mediaName", item.Name, "position", position, "error", encoderErr)
+						state.runWhilePlaying(encoder, func() {
+							startPlayback(cmd, client, guildId, channelId, nowPlaying, position, nextAttempt)
+						})
+					} else {
+						zap.S().Warnw("Playback of media item failed, skipping it", "mediaName", item.Name, "error", encoderErr)
+						client.ReplyMessage(statusMsg, EmojiFailed+"Failed to play `"+item.Name+"`: "+describeError(encoderErr))
+						state.runWhilePlaying(encoder, func() {
+							state.recordHistory(nowPlaying, HistoryFailed)
+							playNext(cmd, client, guildId, channelId)
+						})
+					}
+				case codec.EndStopped:
+					zap.S().Debugln("Playback was stopped, not starting next one")
+				}
 				return
+			case event := <-voiceClient.Events:
+				// Failures of the encoder end it, other errors come from the mixer or the voice connection
+				if event == discord.VoiceEventError && encoder.Err() == nil {
+					zap.S().Warnw("Playback finished with error, sending error message", "mediaName", item.Name)
+					client.ReplyMessage(statusMsg, EmojiFailed+"Something went wrong during playback")
+					state.runWhilePlaying(encoder, func() {
+						state.recordHistory(nowPlaying, HistoryFailed)
+						stopPlayback(client, state, guildId)
+					})
+					return
+				}
 			}
 		}
 	}()
 }
+
+// drainVoiceEvents discards the pending events of the voice client
+func drainVoiceEvents(voiceClient *discord.VoiceClient) {
+	for {
+		select {
+		case <-voiceClient.Events:
+		default:
+			return
+		}
+	}
+}
+
+// seekPlayback restarts the current media item at the offset. It returns false if nothing is playing
+func seekPlayback(cmd discord.CommandBuffer, client *discord.Client, offset time.Duration) bool {
+	state := GetBotState(cmd.Message)
+	voiceState, inVoiceChannel := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+	voiceClient := client.GetVoiceClient(cmd.Message.GuildId)
+	if state.NowPlaying == nil || !inVoiceChannel || voiceClient == nil || !voiceClient.IsPlaying() {
+		return false
+	}
+
+	zap.S().Debugw("Seeking in current media item", "mediaName", state.NowPlaying.Item.Name, "offset", offset)
+	startPlayback(cmd, client, voiceState.GuildId, voiceState.ChannelId, state.NowPlaying, offset, 0)
+	return true
+}
+
+func joinVoiceChannel(client *discord.Client, guildId string, channelId string) (*discord.VoiceClient, error) {
+	zap.S().Debugln("Joining voice channel")
+	voiceClient, err := client.JoinVoiceChannel(guildId, channelId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !voiceClient.IsReady() {
+		zap.S().Debugln("Waiting for voice client to become ready")
+		for event := range voiceClient.Events {
+			if event == discord.VoiceEventReady {
+				break
+			}
+		}
+	}
+
+	return voiceClient, nil
+}
+
+// getMixer returns the audio mixer for the voice client, starting a new one if the voice client changed
+func getMixer(state *BotState, voiceClient *discord.VoiceClient) (*codec.Mixer, error) {
+	if state.Mixer != nil && state.Mixer.Sink() == codec.AudioSink(voiceClient.VoiceStream) {
+		return state.Mixer, nil
+	}
+
+	if state.Mixer != nil {
+		state.Mixer.Stop()
+	}
+
+	mixer := codec.NewMixer(voiceClient.VoiceStream)
+	err := mixer.Start()
+	if err != nil {
+		return nil, err
+	}
+	state.Mixer = mixer
+	return mixer, nil
+}
+
+// stopPlayback stops all audio and leaves the voice channel
+func stopPlayback(client *discord.Client, state *BotState, guildId string) {
+	if state.Encoder != nil {
+		state.Encoder.Stop()
+	}
+	if state.Mixer != nil {
+		state.Mixer.Stop()
+		state.Mixer = nil
+	}
+	client.LeaveVoiceChannel(guildId)
+	state.markChanged()
+}
+
+// skipUnplayable records an item that could not be played. The item is remembered like a played one, because
+// autoplay and mixes continue from the previous item and would pick the same item again otherwise.
+func (state *BotState) skipUnplayable(nowPlaying *NowPlaying) {
+	state.recordHistory(nowPlaying, HistoryFailed)
+	state.rememberPlayed(nowPlaying.queueItem().MediaItem)
+}
+
+// resolveStream returns the URL that ffmpeg can read the media item from, and whether yt-dlp reports it as live.
+// If fresh is set, URLs are always resolved again, because the cached URL was rejected.
+func resolveStream(item ytapi.MediaItem, fresh bool) (ytdlp.StreamInfo, error) {
+	if item.Type == ytapi.MediaTypeFile || item.Type == ytapi.MediaTypeStream {
+		return ytdlp.StreamInfo{Url: item.Url}, nil
+	}
+
+	zap.S().Debugw("Fetching streaming URL", "mediaName", item.Name, "mediaUrl", item.Url, "fresh", fresh)
+	if fresh {
+		return ytdlp.RefreshStream(context.Background(), item.Url)
+	}
+	return ytdlp.GetStream(context.Background(), item.Url)
+}
+
+func describeError(err error) string {
+	var ffmpegErr *codec.FfmpegError
+	var ytdlpErr *ytdlp.YtdlpError
+	if errors.As(err, &ffmpegErr) {
+		return ffmpegErr.Kind.Error()
+	} else if errors.As(err, &ytdlpErr) {
+		return ytdlpErr.Kind.Error()
+	} else if errors.Is(err, ytdlp.ErrTimeout) {
+		return ytdlp.ErrTimeout.Error()
+	} else if errors.Is(err, codec.ErrProbeTimeout) {
+		return codec.ErrProbeTimeout.Error()
+	}
+	return "an unknown error occurred"
+}
@@ -0,0 +1,321 @@
+package core
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Queue is the ordered list of media items that are played next. Positions are 0-based.
+// It is safe for concurrent use, as it is changed both by commands and by playback advancing to the next item.
+// Every added item gets a QueueId that stays the same while its position changes.
+type Queue struct {
+	items       []QueueItem
+	nextId      uint64
+	rand        *rand.Rand
+	subscribers []chan interface{}
+	mutex       sync.Mutex
+}
+
+func (queue *Queue) Len() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.items)
+}
+
+// Items returns a copy of the items in the queue
+func (queue *Queue) Items() []QueueItem {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return append([]QueueItem(nil), queue.items...)
+}
+
+func (queue *Queue) Get(idx int) (QueueItem, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if idx < 0 || idx >= len(queue.items) {
+		return QueueItem{}, false
+	}
+	return queue.items[idx], true
+}
+
+// Find returns the current position of the item with the QueueId, or -1 if it is not in the queue anymore
+func (queue *Queue) Find(queueId uint64) int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.indexOf(queueId)
+}
+
+// Append adds items to the end of the queue
+func (queue *Queue) Append(items ...QueueItem) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.items = append(queue.items, queue.assignIds(items)...)
+	queue.notify()
+}
+
+// Prepend adds items to the front of the queue, keeping their order
+func (queue *Queue) Prepend(items ...QueueItem) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.items = append(queue.assignIds(items), queue.items...)
+	queue.notify()
+}
+
+// Pop removes the first item of the queue and returns it
+func (queue *Queue) Pop() (QueueItem, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if len(queue.items) == 0 {
+		return QueueItem{}, false
+	}
+	item := queue.items[0]
+	queue.items = append([]QueueItem(nil), queue.items[1:]...)
+	queue.notify()
+	return item, true
+}
+
+func (queue *Queue) Clear() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.items = nil
+	queue.notify()
+}
+
+// Move moves the item at position from to position to, shifting the items in between
+func (queue *Queue) Move(from int, to int) bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if from < 0 || from >= len(queue.items) || to < 0 || to >= len(queue.items) {
+		return false
+	}
+
+	item := queue.items[from]
+	items := make([]QueueItem, 0, len(queue.items))
+	items = append(items, queue.items[:from]...)
+	items = append(items, queue.items[from+1:]...)
+	items = append(items[:to], append([]QueueItem{item}, items[to:]...)...)
+	queue.items = items
+	queue.notify()
+	return true
+}
+
+// Remove removes the item at the position and returns it
+func (queue *Queue) Remove(idx int) (QueueItem, bool) {
+	removed := queue.RemoveRange(idx, idx)
+	if len(removed) == 0 {
+		return QueueItem{}, false
+	}
+	return removed[0], true
+}
+
+// RemoveRange removes the items from position from to position to, both inclusive, and returns them.
+// Nothing is removed if the range is not within the queue.
+func (queue *Queue) RemoveRange(from int, to int) []QueueItem {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if from < 0 || to >= len(queue.items) || from > to {
+		return nil
+	}
+
+	removed := append([]QueueItem(nil), queue.items[from:to+1]...)
+	queue.items = append(append([]QueueItem(nil), queue.items[:from]...), queue.items[to+1:]...)
+	queue.notify()
+	return removed
+}
+
+// RemoveId removes the item with the QueueId and returns it
+func (queue *Queue) RemoveId(queueId uint64) (QueueItem, bool) {
+	removed := queue.removeWhere(func(item QueueItem) bool {
+		return item.QueueId == queueId
+	})
+	if len(removed) == 0 {
+		return QueueItem{}, false
+	}
+	return removed[0], true
+}
+
+// RemoveRequester removes all items requested by the user and returns them
+func (queue *Queue) RemoveRequester(userId string) []QueueItem {
+	return queue.removeWhere(func(item QueueItem) bool {
+		return item.Requester.Id == userId
+	})
+}
+
+// Dedupe removes items that are already in the queue at an earlier position, and returns them.
+// Items are compared by their video id, or by their URL if they have no id.
+func (queue *Queue) Dedupe() []QueueItem {
+	seen := make(map[string]bool)
+	return queue.removeWhere(func(item QueueItem) bool {
+		key := item.Id
+		if len(key) == 0 {
+			key = item.Url
+		}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+		return false
+	})
+}
+
+// SkipTo removes the items before the item with the QueueId, so that it is played next, and returns the
+// removed items. It returns false if the item is not in the queue anymore.
+func (queue *Queue) SkipTo(queueId uint64) ([]QueueItem, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	idx := queue.indexOf(queueId)
+	if idx < 0 {
+		return nil, false
+	}
+
+	skipped := append([]QueueItem(nil), queue.items[:idx]...)
+	queue.items = append([]QueueItem(nil), queue.items[idx:]...)
+	queue.notify()
+	return skipped, true
+}
+
+func (queue *Queue) Reverse() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for i, j := 0, len(queue.items)-1; i < j; i, j = i+1, j-1 {
+		queue.items[i], queue.items[j] = queue.items[j], queue.items[i]
+	}
+	queue.notify()
+}
+
+// Shuffle puts the items into a random order using a Fisher-Yates shuffle. The first keep items stay in place.
+func (queue *Queue) Shuffle(keep int) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	keep = max(keep, 0)
+	if keep >= len(queue.items) {
+		return
+	}
+	queue.shuffle(queue.items[keep:])
+	queue.notify()
+}
+
+// ShuffleFair shuffles the items, and then interleaves them by requester, so that each requester gets a turn
+// before anyone's next item is played. The order of the requesters is random as well.
+func (queue *Queue) ShuffleFair() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	var requesters []string
+	byRequester := make(map[string][]QueueItem)
+	for _, item := range queue.items {
+		if _, ok := byRequester[item.Requester.Id]; !ok {
+			requesters = append(requesters, item.Requester.Id)
+		}
+		byRequester[item.Requester.Id] = append(byRequester[item.Requester.Id], item)
+	}
+
+	for _, items := range byRequester {
+		queue.shuffle(items)
+	}
+	for i := len(requesters) - 1; i > 0; i-- {
+		j := queue.random().Intn(i + 1)
+		requesters[i], requesters[j] = requesters[j], requesters[i]
+	}
+
+	result := make([]QueueItem, 0, len(queue.items))
+	for round := 0; len(result) < len(queue.items); round++ {
+		for _, requester := range requesters {
+			if items := byRequester[requester]; round < len(items) {
+				result = append(result, items[round])
+			}
+		}
+	}
+	queue.items = result
+	queue.notify()
+}
+
+// Subscribe returns a channel that receives a value after the queue changed. Changes that happen while
+// a value is still pending are merged into it.
+func (queue *Queue) Subscribe() <-chan interface{} {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	subscriber := make(chan interface{}, 1)
+	queue.subscribers = append(queue.subscribers, subscriber)
+	return subscriber
+}
+
+// Unsubscribe stops notifying the channel returned by Subscribe
+func (queue *Queue) Unsubscribe(subscriber <-chan interface{}) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for idx, candidate := range queue.subscribers {
+		if candidate == subscriber {
+			queue.subscribers = append(queue.subscribers[:idx:idx], queue.subscribers[idx+1:]...)
+			return
+		}
+	}
+}
+
+// notify informs the subscribers about a change without blocking. The mutex must be held.
+func (queue *Queue) notify() {
+	for _, subscriber := range queue.subscribers {
+		select {
+		case subscriber <- nil:
+		default:
+		}
+	}
+}
+
+// assignIds returns a copy of the items with new QueueIds. The mutex must be held.
+func (queue *Queue) assignIds(items []QueueItem) []QueueItem {
+	result := make([]QueueItem, 0, len(items))
+	for _, item := range items {
+		queue.nextId++
+		item.QueueId = queue.nextId
+		result = append(result, item)
+	}
+	return result
+}
+
+// indexOf returns the position of the item with the QueueId, or -1. The mutex must be held.
+func (queue *Queue) indexOf(queueId uint64) int {
+	for idx, item := range queue.items {
+		if item.QueueId == queueId {
+			return idx
+		}
+	}
+	return -1
+}
+
+// shuffle shuffles the items in place. The mutex must be held.
+func (queue *Queue) shuffle(items []QueueItem) {
+	for i := len(items) - 1; i > 0; i-- {
+		j := queue.random().Intn(i + 1)
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+func (queue *Queue) random() *rand.Rand {
+	if queue.rand == nil {
+		queue.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return queue.rand
+}
+
+// removeWhere removes all items that match and returns them
+func (queue *Queue) removeWhere(matches func(item QueueItem) bool) []QueueItem {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	var removed []QueueItem
+	kept := make([]QueueItem, 0, len(queue.items))
+	for _, item := range queue.items {
+		if matches(item) {
+			removed = append(removed, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	queue.items = kept
+	if len(removed) > 0 {
+		queue.notify()
+	}
+	return removed
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"ytbot/discord"
+)
+
+var (
+	positionRangePattern = regexp.MustCompile(`^(\d+)-(\d+)$`)
+	userMentionPattern   = regexp.MustCompile(`^<@!?(\d+)>$`)
+	queueIdPattern       = regexp.MustCompile(`^id:(\d+)$`)
+)
+
+// parseQueueId reads references to items by their QueueId, like `id:17`. Unlike positions, they keep
+// referring to the same item while the queue advances.
+func parseQueueId(arg string) (uint64, bool) {
+	match := queueIdPattern.FindStringSubmatch(strings.ToLower(arg))
+	if match == nil {
+		return 0, false
+	}
+	queueId, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return queueId, true
+}
+
+// formatQueueId formats the QueueId in the form that parseQueueId reads
+func formatQueueId(queueId uint64) string {
+	return "id:" + strconv.FormatUint(queueId, 10)
+}
+
+// RemoveCommand removes a single item by its position or its id, a range of items like `3-7`, or all items
+// requested by a mentioned user
+func RemoveCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	botState := GetBotState(cmd.Message)
+	arg := strings.TrimSpace(cmd.GetStringAll())
+
+	if queueId, ok := parseQueueId(arg); ok {
+		item, ok := botState.Queue.RemoveId(queueId)
+		if !ok {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item with that id in the queue")
+			return
+		}
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Item `"+item.Name+"` was removed.")
+		return
+	}
+
+	if match := userMentionPattern.FindStringSubmatch(arg); match != nil {
+		removed := botState.Queue.RemoveRequester(match[1])
+		if len(removed) == 0 {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There are no items requested by that user")
+			return
+		}
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Removed **"+strconv.Itoa(len(removed))+" items** requested by **"+removed[0].Requester.Username+"**")
+		return
+	}
+
+	if match := positionRangePattern.FindStringSubmatch(arg); match != nil {
+		from, _ := strconv.Atoi(match[1])
+		to, _ := strconv.Atoi(match[2])
+		removed := botState.Queue.RemoveRange(from-1, to-1)
+		if len(removed) == 0 {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There are no items in that range")
+			return
+		}
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Removed **"+strconv.Itoa(len(removed))+" items** at positions #"+match[1]+" to #"+match[2])
+		return
+	}
+
+	index, _ := strconv.Atoi(arg)
+	item, ok := botState.Queue.Remove(index - 1)
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item with that index")
+		return
+	}
+	client.ReplyMessage(cmd.Message, EmojiSuccess+"Item `"+item.Name+"` at position #"+strconv.Itoa(index)+" was removed.")
+}
+
+// ShuffleCommand shuffles the queue, keeping the given number of items at its front in place,
+// or interleaves the items by requester using `.shuffle fair`
+func ShuffleCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	queue := &GetBotState(cmd.Message).Queue
+	arg := strings.ToLower(cmd.GetStringOrDefault(""))
+
+	if arg == "fair" {
+		queue.ShuffleFair()
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Shuffled the queue, taking turns between requesters")
+		return
+	}
+
+	keep := 0
+	if len(arg) > 0 {
+		var err error
+		keep, err = strconv.Atoi(arg)
+		if err != nil || keep < 0 {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"Usage: `.shuffle <number of items to keep in place or fair>`")
+			return
+		}
+	}
+
+	queue.Shuffle(keep)
+	if keep > 0 {
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Shuffled the queue after item #"+strconv.Itoa(keep))
+	} else {
+		client.ReplyMessage(cmd.Message, EmojiSuccess+"Shuffled the queue")
+	}
+}
+
+func DedupeCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	removed := GetBotState(cmd.Message).Queue.Dedupe()
+	if len(removed) == 0 {
+		client.ReplyMessage(cmd.Message, EmojiNeutral+"There are no duplicates in the queue")
+		return
+	}
+	client.ReplyMessage(cmd.Message, EmojiSuccess+"Removed **"+strconv.Itoa(len(removed))+" duplicates** from the queue")
+}
+
+func ReverseCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	GetBotState(cmd.Message).Queue.Reverse()
+	client.ReplyMessage(cmd.Message, EmojiSuccess+"Reversed the queue")
+}
+
+// SkipToCommand skips to the item at the given position or with the given id, and re-adds skipped items to a looped queue
+func SkipToCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	voiceState, ok := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
+		return
+	}
+
+	botState := GetBotState(cmd.Message)
+	arg := strings.TrimSpace(cmd.GetStringAll())
+
+	queueId, isId := parseQueueId(arg)
+	if !isId {
+		position, _ := strconv.Atoi(arg)
+		target, ok := botState.Queue.Get(position - 1)
+		if !ok {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item at that position")
+			return
+		}
+		queueId = target.QueueId
+	}
+
+	skipped, ok := botState.Queue.SkipTo(queueId)
+	if !ok {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no item with that id in the queue")
+		return
+	}
+
+	skipCurrent(cmd, client)
+	if GetGuildSettings(cmd.Message.GuildId).Loop == LoopQueue {
+		botState.Queue.Append(skipped...)
+	}
+	playNext(cmd, client, voiceState.GuildId, voiceState.ChannelId)
+}
+
+// PlayNextCommand works like PlayCommand, but adds the items to the front of the queue
+func PlayNextCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	playQuery(cmd, client, true)
+}
@@ -0,0 +1,532 @@
+package core
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"ytbot/discord"
+	"ytbot/ytapi"
+)
+
+// testItem creates a queue item for the video id, requested by the user. Items with an empty id only have a URL.
+func testItem(id string, requester string) QueueItem {
+	return QueueItem{
+		MediaItem: ytapi.MediaItem{Id: id, Name: id, Url: "https://youtube.com/watch?v=" + id},
+		Requester: discord.User{Id: requester},
+	}
+}
+
+// newTestQueue creates a queue with a seeded random source and the items. Items are written as `<id>` or
+// `<id>@<requester>`, so `a@1 b@2` is video a requested by user 1 followed by video b requested by user 2.
+func newTestQueue(seed int64, items string) *Queue {
+	queue := &Queue{rand: rand.New(rand.NewSource(seed))}
+	for _, field := range strings.Fields(items) {
+		id, requester, _ := strings.Cut(field, "@")
+		queue.Append(testItem(id, requester))
+	}
+	return queue
+}
+
+// queueIds returns the names of the items, which are their video ids for items created by testItem, separated by spaces
+func queueIds(items []QueueItem) string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Name)
+	}
+	return strings.Join(ids, " ")
+}
+
+func sortedIds(items []QueueItem) string {
+	ids := strings.Fields(queueIds(items))
+	sort.Strings(ids)
+	return strings.Join(ids, " ")
+}
+
+func TestQueueShuffle(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+		keep  int
+	}{
+		{"empty", "", 0},
+		{"single", "a", 0},
+		{"all", "a b c d e f g h", 0},
+		{"keep first", "a b c d e f g h", 1},
+		{"keep several", "a b c d e f g h", 3},
+		{"keep all", "a b c", 3},
+		{"keep more than all", "a b c", 5},
+		{"negative keep", "a b c d e f g h", -2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := newTestQueue(1, test.items)
+			before := queue.Items()
+			queue.Shuffle(test.keep)
+			after := queue.Items()
+
+			if sortedIds(after) != sortedIds(before) {
+				t.Fatalf("expected the same items after shuffling, got %s", queueIds(after))
+			}
+			keep := max(test.keep, 0)
+			if keep > len(before) {
+				keep = len(before)
+			}
+			if queueIds(after[:keep]) != queueIds(before[:keep]) {
+				t.Errorf("expected the first %d items to stay in place, got %s", keep, queueIds(after))
+			}
+
+			// The same seed produces the same order
+			other := newTestQueue(1, test.items)
+			other.Shuffle(test.keep)
+			if queueIds(other.Items()) != queueIds(after) {
+				t.Errorf("expected a seeded shuffle to be repeatable, got %s and %s", queueIds(after), queueIds(other.Items()))
+			}
+		})
+	}
+}
+
+func TestQueueShuffleChangesOrder(t *testing.T) {
+	queue := newTestQueue(7, "a b c d e f g h i j k l m n o p")
+	queue.Shuffle(0)
+	if queueIds(queue.Items()) == "a b c d e f g h i j k l m n o p" {
+		t.Error("expected the order to change")
+	}
+}
+
+func TestQueueShuffleFair(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+	}{
+		{"empty", ""},
+		{"single requester", "a@1 b@1 c@1 d@1"},
+		{"equal turns", "a@1 b@1 c@2 d@2 e@3 f@3"},
+		{"one requester dominates", "a@1 b@1 c@1 d@1 e@1 f@2 g@3"},
+		{"interleaved input", "a@1 b@2 c@1 d@2 e@1 f@3 g@1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				queue := newTestQueue(seed, test.items)
+				before := queue.Items()
+				queue.ShuffleFair()
+				after := queue.Items()
+
+				if sortedIds(after) != sortedIds(before) {
+					t.Fatalf("expected the same items after shuffling, got %s", queueIds(after))
+				}
+				assertFairOrder(t, before, after)
+			}
+		})
+	}
+}
+
+// assertFairOrder checks that the items are played in rounds, in which each requester with items left gets one turn
+func assertFairOrder(t *testing.T, before []QueueItem, after []QueueItem) {
+	t.Helper()
+	remaining := make(map[string]int)
+	for _, item := range before {
+		remaining[item.Requester.Id]++
+	}
+
+	for position := 0; position < len(after); {
+		round := make(map[string]bool)
+		requesters := 0
+		for _, count := range remaining {
+			if count > 0 {
+				requesters++
+			}
+		}
+
+		for i := 0; i < requesters; i, position = i+1, position+1 {
+			requester := after[position].Requester.Id
+			if round[requester] || remaining[requester] == 0 {
+				t.Fatalf("requester %s got a second turn in a round at position %d: %s", requester, position, queueIds(after))
+			}
+			round[requester] = true
+			remaining[requester]--
+		}
+	}
+}
+
+func TestQueueDedupe(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []QueueItem
+		expected string
+		removed  string
+	}{
+		{"no duplicates", []QueueItem{testItem("a", "1"), testItem("b", "1")}, "a b", ""},
+		{"keeps first occurrence", []QueueItem{testItem("a", "1"), testItem("b", "1"), testItem("a", "2"), testItem("c", "1"), testItem("b", "3")}, "a b c", "a b"},
+		{"all duplicates", []QueueItem{testItem("a", "1"), testItem("a", "1"), testItem("a", "1")}, "a", "a a"},
+		{
+			"compares urls of items without id",
+			[]QueueItem{
+				{MediaItem: ytapi.MediaItem{Name: "x", Url: "file:///x.mp3"}},
+				{MediaItem: ytapi.MediaItem{Name: "y", Url: "file:///y.mp3"}},
+				{MediaItem: ytapi.MediaItem{Name: "x", Url: "file:///x.mp3"}},
+			},
+			"x y", "x",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := &Queue{}
+			queue.Append(test.items...)
+			removed := queue.Dedupe()
+
+			if ids := queueIds(queue.Items()); ids != test.expected {
+				t.Errorf("expected %q to be left, got %q", test.expected, ids)
+			}
+			if ids := queueIds(removed); ids != test.removed {
+				t.Errorf("expected %q to be removed, got %q", test.removed, ids)
+			}
+		})
+	}
+}
+
+func TestQueueReverse(t *testing.T) {
+	tests := []struct {
+		items    string
+		expected string
+	}{
+		{"", ""},
+		{"a", "a"},
+		{"a b", "b a"},
+		{"a b c d e", "e d c b a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.items, func(t *testing.T) {
+			queue := newTestQueue(1, test.items)
+			queue.Reverse()
+			if ids := queueIds(queue.Items()); ids != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestQueueSkipTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    string
+		target   string
+		expected string
+		skipped  string
+	}{
+		{"first item", "a b c", "a", "a b c", ""},
+		{"middle item", "a b c d", "c", "c d", "a b"},
+		{"last item", "a b c", "c", "c", "a b"},
+		{"missing item", "a b c", "", "a b c", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := newTestQueue(1, test.items)
+			queueId := uint64(999)
+			for _, item := range queue.Items() {
+				if item.Id == test.target {
+					queueId = item.QueueId
+				}
+			}
+
+			skipped, ok := queue.SkipTo(queueId)
+			if ok != (test.target != "") {
+				t.Fatalf("expected SkipTo to return %v", test.target != "")
+			}
+			if ids := queueIds(queue.Items()); ids != test.expected {
+				t.Errorf("expected %q to be left, got %q", test.expected, ids)
+			}
+			if ids := queueIds(skipped); ids != test.skipped {
+				t.Errorf("expected %q to be skipped, got %q", test.skipped, ids)
+			}
+		})
+	}
+}
+
+func TestQueueRemoveRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     int
+		to       int
+		expected string
+		removed  string
+	}{
+		{"single item", 1, 1, "a c d e", "b"},
+		{"range", 1, 3, "a e", "b c d"},
+		{"whole queue", 0, 4, "", "a b c d e"},
+		{"negative start", -1, 2, "a b c d e", ""},
+		{"end after queue", 3, 5, "a b c d e", ""},
+		{"reversed range", 3, 1, "a b c d e", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := newTestQueue(1, "a b c d e")
+			removed := queue.RemoveRange(test.from, test.to)
+			if ids := queueIds(queue.Items()); ids != test.expected {
+				t.Errorf("expected %q to be left, got %q", test.expected, ids)
+			}
+			if ids := queueIds(removed); ids != test.removed {
+				t.Errorf("expected %q to be removed, got %q", test.removed, ids)
+			}
+		})
+	}
+}
+
+func TestQueueRemoveRequester(t *testing.T) {
+	tests := []struct {
+		name      string
+		requester string
+		expected  string
+		removed   string
+	}{
+		{"some items", "1", "b d", "a c e"},
+		{"single item", "2", "a c d e", "b"},
+		{"unknown requester", "4", "a b c d e", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := newTestQueue(1, "a@1 b@2 c@1 d@3 e@1")
+			removed := queue.RemoveRequester(test.requester)
+			if ids := queueIds(queue.Items()); ids != test.expected {
+				t.Errorf("expected %q to be left, got %q", test.expected, ids)
+			}
+			if ids := queueIds(removed); ids != test.removed {
+				t.Errorf("expected %q to be removed, got %q", test.removed, ids)
+			}
+		})
+	}
+}
+
+func TestQueueKeepsIdsWhenReordering(t *testing.T) {
+	queue := newTestQueue(3, "a b c d e f")
+	ids := make(map[string]uint64)
+	for _, item := range queue.Items() {
+		ids[item.Id] = item.QueueId
+	}
+
+	queue.Shuffle(0)
+	queue.Reverse()
+	queue.ShuffleFair()
+
+	after := make(map[string]uint64)
+	for _, item := range queue.Items() {
+		after[item.Id] = item.QueueId
+	}
+	if !reflect.DeepEqual(ids, after) {
+		t.Errorf("expected the queue ids to stay the same, got %v and %v", ids, after)
+	}
+}
+
+func TestQueueRemoveId(t *testing.T) {
+	queue := newTestQueue(1, "a b c")
+	target := queue.Items()[1]
+	queue.Pop()
+
+	item, ok := queue.RemoveId(target.QueueId)
+	if !ok || item.Id != "b" {
+		t.Fatalf("expected b to be removed after the queue advanced, got %+v", item)
+	}
+	if ids := queueIds(queue.Items()); ids != "c" {
+		t.Errorf("expected c to be left, got %q", ids)
+	}
+	if _, ok := queue.RemoveId(target.QueueId); ok {
+		t.Error("expected an item to be removed only once")
+	}
+}
+
+func TestParseQueueId(t *testing.T) {
+	tests := []struct {
+		arg      string
+		expected uint64
+		ok       bool
+	}{
+		{"id:17", 17, true},
+		{"ID:3", 3, true},
+		{formatQueueId(42), 42, true},
+		{"17", 0, false},
+		{"id:", 0, false},
+		{"id:-1", 0, false},
+		{"id:abc", 0, false},
+		{"id:99999999999999999999999", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.arg, func(t *testing.T) {
+			queueId, ok := parseQueueId(test.arg)
+			if ok != test.ok || queueId != test.expected {
+				t.Errorf("expected %d and %v, got %d and %v", test.expected, test.ok, queueId, ok)
+			}
+		})
+	}
+}
+
+func TestQueueAppendAndPrepend(t *testing.T) {
+	queue := newTestQueue(1, "a b")
+	queue.Append(testItem("c", ""), testItem("d", ""))
+	queue.Prepend(testItem("x", ""), testItem("y", ""))
+
+	items := queue.Items()
+	if ids := queueIds(items); ids != "x y a b c d" {
+		t.Fatalf("expected x y a b c d, got %q", ids)
+	}
+
+	seen := make(map[uint64]bool)
+	for _, item := range items {
+		if item.QueueId == 0 || seen[item.QueueId] {
+			t.Fatalf("expected unique queue ids, got %d for %s", item.QueueId, item.Id)
+		}
+		seen[item.QueueId] = true
+	}
+
+	// Adding the same item again gives it a new id
+	queue.Append(items[0])
+	if last, _ := queue.Get(queue.Len() - 1); last.QueueId == items[0].QueueId {
+		t.Error("expected an added item to get a new queue id")
+	}
+}
+
+func TestQueuePop(t *testing.T) {
+	queue := newTestQueue(1, "a b")
+	for _, expected := range []string{"a", "b"} {
+		item, ok := queue.Pop()
+		if !ok || item.Id != expected {
+			t.Fatalf("expected to pop %s, got %+v", expected, item)
+		}
+	}
+	if _, ok := queue.Pop(); ok {
+		t.Error("expected nothing to be popped from an empty queue")
+	}
+}
+
+func TestQueueMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     int
+		to       int
+		ok       bool
+		expected string
+	}{
+		{"forward", 0, 2, true, "b c a d e"},
+		{"backward", 3, 1, true, "a d b c e"},
+		{"to end", 1, 4, true, "a c d e b"},
+		{"to front", 4, 0, true, "e a b c d"},
+		{"same position", 2, 2, true, "a b c d e"},
+		{"from out of range", 5, 0, false, "a b c d e"},
+		{"to out of range", 0, 5, false, "a b c d e"},
+		{"negative", -1, 0, false, "a b c d e"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := newTestQueue(1, "a b c d e")
+			if ok := queue.Move(test.from, test.to); ok != test.ok {
+				t.Errorf("expected Move to return %v", test.ok)
+			}
+			if ids := queueIds(queue.Items()); ids != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, ids)
+			}
+		})
+	}
+}
+
+func TestQueueSubscribe(t *testing.T) {
+	queue := newTestQueue(1, "a b c")
+	changes := queue.Subscribe()
+
+	assertChanged := func(changed bool, operation string) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !changed {
+				t.Errorf("expected %s not to notify", operation)
+			}
+		default:
+			if changed {
+				t.Errorf("expected %s to notify", operation)
+			}
+		}
+	}
+
+	assertChanged(false, "subscribing")
+	queue.Append(testItem("d", ""))
+	assertChanged(true, "Append")
+
+	// Changes are merged while a notification is pending
+	queue.Pop()
+	queue.Reverse()
+	queue.Move(0, 1)
+	assertChanged(true, "several changes")
+	assertChanged(false, "a merged notification")
+
+	queue.Dedupe()
+	assertChanged(false, "Dedupe without duplicates")
+	queue.RemoveRange(5, 6)
+	assertChanged(false, "an invalid RemoveRange")
+	queue.Clear()
+	assertChanged(true, "Clear")
+
+	queue.Unsubscribe(changes)
+	queue.Append(testItem("e", ""))
+	assertChanged(false, "a change after unsubscribing")
+}
+
+func TestQueueConcurrentPopAndRemove(t *testing.T) {
+	const items = 1000
+	queue := &Queue{}
+	for i := 0; i < items; i++ {
+		queue.Append(testItem(strconv.Itoa(i), strconv.Itoa(i%3)))
+	}
+	// Notifications are sent while the workers change the queue, without anyone receiving them
+	changes := queue.Subscribe()
+
+	var popped, removed int64
+	var wg sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, ok := queue.Pop(); !ok {
+					return
+				}
+				atomic.AddInt64(&popped, 1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for queue.Len() > 0 {
+				if _, ok := queue.Remove(queue.Len() - 1); ok {
+					atomic.AddInt64(&removed, 1)
+				}
+				atomic.AddInt64(&removed, int64(len(queue.RemoveRequester("1"))))
+				if item, ok := queue.Get(0); ok {
+					if _, ok := queue.RemoveId(item.QueueId); ok {
+						atomic.AddInt64(&removed, 1)
+					}
+				}
+				queue.Shuffle(1)
+			}
+		}()
+	}
+	wg.Wait()
+	queue.Unsubscribe(changes)
+
+	if queue.Len() != 0 {
+		t.Errorf("expected the queue to be empty, got %d items", queue.Len())
+	}
+	if total := popped + removed; total != items {
+		t.Errorf("expected each of the %d items to be taken exactly once, got %d", items, total)
+	}
+}
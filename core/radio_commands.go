@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"ytbot/discord"
+	"ytbot/radio"
+	"ytbot/ytapi"
+)
+
+func RadioCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	settings := GetGuildSettings(cmd.Message.GuildId)
+
+	switch arg := cmd.GetStringOrDefault("list"); strings.ToLower(arg) {
+	case "list":
+		if len(settings.Stations) == 0 {
+			client.ReplyMessage(cmd.Message, EmojiNeutral+"There are no saved stations. Use `.radio save <name> <url>` to add one")
+			return
+		}
+
+		names := make([]string, 0, len(settings.Stations))
+		for name := range settings.Stations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var lines []string
+		for _, name := range names {
+			lines = append(lines, "**"+name+"**: <"+settings.Stations[name]+">")
+		}
+		client.ReplyMessage(cmd.Message, "__Saved radio stations__\n"+strings.Join(lines, "\n"))
+	case "save":
+		name := strings.ToLower(cmd.GetStringOrDefault(""))
+		streamUrl := strings.TrimSpace(cmd.GetStringAll())
+		if len(name) == 0 || len(streamUrl) == 0 {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"Usage: `.radio save <name> <url>`")
+			return
+		}
+
+		_, err := radio.LoadMediaItem(streamUrl)
+		if err != nil {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"`"+streamUrl+"` is not a valid radio stream")
+			return
+		}
+
+		settings.Stations[name] = streamUrl
+		saveGuildSettings(cmd, client, settings, EmojiSuccess+"Saved station **"+name+"**")
+	case "remove":
+		name := strings.ToLower(cmd.GetStringOrDefault(""))
+		if _, ok := settings.Stations[name]; !ok {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There is no station named `"+name+"`")
+			return
+		}
+
+		delete(settings.Stations, name)
+		saveGuildSettings(cmd, client, settings, EmojiSuccess+"Removed station **"+name+"**")
+	default:
+		voiceState, inVoiceChannel := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+		if !inVoiceChannel {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
+			return
+		}
+
+		streamUrl := arg
+		if savedUrl, ok := settings.Stations[strings.ToLower(arg)]; ok {
+			streamUrl = savedUrl
+		}
+
+		statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Tuning in...")
+		item, err := radio.LoadMediaItem(streamUrl)
+		if errors.Is(err, radio.ErrNotAStream) {
+			client.EditMessage(statusMsg, EmojiFailed+"`"+arg+"` is neither a saved station nor a radio stream")
+			return
+		} else if err != nil {
+			client.EditMessage(statusMsg, EmojiFailed+"Failed to connect to the radio stream")
+			zap.S().Warnw("Failed to load radio stream", "url", streamUrl, "error", err)
+			return
+		}
+
+		enqueueItems(cmd, client, voiceState, statusMsg, []ytapi.MediaItem{item}, false)
+	}
+}
+
+func saveGuildSettings(cmd discord.CommandBuffer, client *discord.Client, settings *GuildSettings, successMsg string) {
+	err := settings.Save()
+	if err != nil {
+		zap.S().Errorw("Failed to save guild settings", "guildId", cmd.Message.GuildId, "error", err)
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Failed to save the settings")
+		return
+	}
+	client.ReplyMessage(cmd.Message, successMsg)
+}
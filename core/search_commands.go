@@ -0,0 +1,89 @@
+package core
+
+import (
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+	"ytbot/discord"
+	"ytbot/ytapi"
+)
+
+const (
+	searchResultCount = 5
+	searchPickTimeout = 30 * time.Second
+	searchPickFlag    = "-pick"
+)
+
+func SearchCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	voiceState, inVoiceChannel := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+	if !inVoiceChannel {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
+		return
+	}
+
+	query := strings.TrimSpace(cmd.GetStringAll())
+	if len(query) == 0 {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"A search query is required")
+		return
+	}
+
+	statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Searching...")
+	pickSearchResult(cmd, client, voiceState, statusMsg, query, false)
+}
+
+// pickSearchResult lists the top search results in the status message, and adds the result that the
+// author of the command picks by replying with its number to the queue, or to its front if front is set
+func pickSearchResult(cmd discord.CommandBuffer, client *discord.Client, voiceState discord.VoiceState, statusMsg discord.Message, query string, front bool) {
+	results, err := ytapi.Search(query)
+	if err != nil {
+		client.EditMessage(statusMsg, EmojiFailed+"An error occurred while searching")
+		zap.S().Warnw("Failed to search", "query", query, "error", err)
+		return
+	} else if len(results) == 0 {
+		client.EditMessage(statusMsg, EmojiFailed+"No results for `"+query+"`")
+		return
+	}
+	results = results[:min(len(results), searchResultCount)]
+
+	text := "__Results for `" + query + "`__\n"
+	for i, item := range results {
+		text += "**" + strconv.Itoa(i+1) + ".** " + formatMediaItem(item)
+		if len(item.Uploader) > 0 {
+			text += " by **" + item.Uploader + "**"
+		}
+		text += "\n"
+	}
+	text += "Reply with a number to pick a result, or with `cancel`"
+	client.EditMessage(statusMsg, text)
+
+	go func() {
+		reply, ok := client.AwaitMessage(cmd.Message.ChannelId, cmd.Message.Author.Id, func(message discord.Message) bool {
+			return isSearchPick(message.Content, len(results))
+		}, searchPickTimeout)
+		if !ok {
+			client.EditMessage(statusMsg, EmojiNeutral+"No search result was picked in time")
+			return
+		}
+
+		idx, err := strconv.Atoi(strings.TrimSpace(reply.Content))
+		if err != nil {
+			client.EditMessage(statusMsg, EmojiNeutral+"Search was cancelled")
+			return
+		}
+
+		// The queue and playback are only changed by the command loop
+		runOnCommandLoop(func() {
+			enqueueItems(cmd, client, voiceState, statusMsg, []ytapi.MediaItem{results[idx-1]}, front)
+		})
+	}()
+}
+
+func isSearchPick(content string, resultCount int) bool {
+	content = strings.TrimSpace(content)
+	if strings.EqualFold(content, "cancel") {
+		return true
+	}
+	idx, err := strconv.Atoi(content)
+	return err == nil && idx >= 1 && idx <= resultCount
+}
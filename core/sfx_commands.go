@@ -0,0 +1,99 @@
+package core
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"strings"
+	"ytbot/codec"
+	"ytbot/discord"
+	"ytbot/library"
+)
+
+func SfxCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	name := strings.TrimSpace(cmd.GetStringAll())
+	if len(name) == 0 {
+		names, err := library.ListSoundEffects()
+		if errors.Is(err, library.ErrNoSoundEffects) {
+			client.ReplyMessage(cmd.Message, EmojiFailed+"There is no sound effect directory configured")
+		} else if err != nil {
+			zap.S().Warnw("Failed to list sound effects", "error", err)
+			client.ReplyMessage(cmd.Message, EmojiFailed+"Failed to list the sound effects")
+		} else if len(names) == 0 {
+			client.ReplyMessage(cmd.Message, EmojiNeutral+"There are no sound effects")
+		} else {
+			client.ReplyMessage(cmd.Message, "__Sound effects__\n`"+strings.Join(names, "`, `")+"`")
+		}
+		return
+	}
+
+	voiceState, inVoiceChannel := client.GetVoiceState(cmd.Message.GuildId, cmd.Message.Author.Id)
+	if !inVoiceChannel {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"You are not in a voice channel")
+		return
+	}
+
+	path, err := library.FindSoundEffect(name)
+	if errors.Is(err, library.ErrNoSoundEffects) {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no sound effect directory configured")
+		return
+	} else if err != nil {
+		zap.S().Warnw("Failed to find sound effect", "name", name, "error", err)
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Failed to load the sound effect")
+		return
+	} else if len(path) == 0 {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no sound effect named `"+name+"`")
+		return
+	}
+
+	state := GetBotState(cmd.Message)
+	joining := client.GetVoiceClient(voiceState.GuildId) == nil
+	voiceClient, err := joinVoiceChannel(client, voiceState.GuildId, voiceState.ChannelId)
+	if err != nil {
+		zap.S().Errorw("Failed to join voice channel", "guildId", voiceState.GuildId, "error", err)
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Failed to join voice channel")
+		return
+	}
+
+	if joining {
+		state.overlaysJoined = true
+	}
+
+	mixer, err := getMixer(state, voiceClient)
+	if err != nil {
+		zap.S().Errorw("Failed to start audio mixer", "guildId", voiceState.GuildId, "error", err)
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Failed to start audio stream")
+		return
+	}
+
+	encoder := codec.NewEncoder(codec.Source{Url: path, Overlay: true}, mixer)
+	err = encoder.Start()
+	if err != nil {
+		zap.S().Errorw("Failed to play sound effect", "name", name, "error", err)
+		client.ReplyMessage(cmd.Message, EmojiFailed+"Failed to play the sound effect")
+		leaveAfterOverlays(client, state, voiceState.GuildId)
+		return
+	}
+
+	state.overlays++
+	go func() {
+		<-encoder.Ended()
+		runOnCommandLoop(func() {
+			state.overlays--
+			leaveAfterOverlays(client, state, voiceState.GuildId)
+		})
+	}()
+}
+
+// leaveAfterOverlays leaves the voice channel if it was joined to play sound effects, and all of them ended
+// without a media item being played meanwhile
+func leaveAfterOverlays(client *discord.Client, state *BotState, guildId string) {
+	if !state.overlaysJoined || state.overlays > 0 {
+		return
+	}
+	state.overlaysJoined = false
+
+	if voiceClient := client.GetVoiceClient(guildId); voiceClient != nil && !voiceClient.IsPlaying() && !state.isPlaying() {
+		zap.S().Debugw("Leaving voice channel after playing sound effects", "guildId", guildId)
+		stopPlayback(client, state, guildId)
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"go.uber.org/zap"
+	"time"
+	"ytbot/codec"
+	"ytbot/discord"
+	"ytbot/sponsorblock"
+	"ytbot/ytapi"
+)
+
+const (
+	segmentWatchInterval = 250 * time.Millisecond
+	// minSegmentSkip avoids seeking if only the last moments of a segment are left
+	minSegmentSkip = time.Second
+)
+
+// skipSegments seeks over the SponsorBlock segments of the playing video until the encoder is done.
+// The segments are loaded once per media item, and kept when playback is restarted.
+func skipSegments(cmd discord.CommandBuffer, client *discord.Client, guildId string, channelId string, nowPlaying *NowPlaying, encoder *codec.Encoder) {
+	segments, loaded := nowPlaying.sponsorSegments()
+	if !loaded {
+		item := nowPlaying.queueItem()
+		if !sponsorblock.Enabled() || item.Type != ytapi.MediaTypeYouTube || len(item.Id) == 0 || item.IsLive {
+			return
+		}
+
+		var err error
+		segments, err = sponsorblock.GetSegments(item.Id)
+		if err != nil {
+			zap.S().Warnw("Failed to load SponsorBlock segments", "videoId", item.Id, "error", err)
+		}
+		nowPlaying.setSponsorSegments(segments)
+	}
+	if len(segments) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(segmentWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		position := encoder.Position()
+		for _, segment := range segments {
+			if position < segment.Start || position >= segment.End-minSegmentSkip {
+				continue
+			}
+
+			zap.S().Infow("Skipping SponsorBlock segment", "mediaName", nowPlaying.Item.Name, "category", segment.Category, "position", position, "end", segment.End)
+			nowPlaying.addSkipped(segment.End-position, segment.Description())
+
+			state := GetBotState(cmd.Message)
+			state.runWhilePlaying(encoder, func() {
+				if end := nowPlaying.Item.EndOffset; end > 0 && segment.End >= end {
+					// The segment lasts until the end of the played part, so there is nothing left to play
+					state.recordHistory(nowPlaying, HistoryFinished)
+					playFinished(cmd, client, guildId, channelId)
+				} else {
+					startPlayback(cmd, client, guildId, channelId, nowPlaying, segment.End, 0)
+				}
+			})
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-encoder.Done():
+			return
+		}
+	}
+}
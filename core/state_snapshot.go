@@ -0,0 +1,180 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"ytbot/config"
+	"ytbot/discord"
+	"ytbot/ytapi"
+)
+
+// snapshotPositionInterval is the interval at which the playback position is saved while playing
+const snapshotPositionInterval = 10 * time.Second
+
+// stateSnapshot is the part of a BotState that is saved in the data directory to survive restarts.
+// The loop mode is part of the GuildSettings instead. Media items that can not be resumed are left out.
+type stateSnapshot struct {
+	TextChannelId string            `json:"textChannelId"`
+	Playback      *playbackSnapshot `json:"playback,omitempty"`
+	Queue         []QueueItem       `json:"queue"`
+}
+
+// playbackSnapshot is a media item that was playing, together with the position it was played at
+type playbackSnapshot struct {
+	Item           QueueItem     `json:"item"`
+	Position       time.Duration `json:"position"`
+	VoiceChannelId string        `json:"voiceChannelId"`
+	Autoplay       bool          `json:"autoplay"`
+}
+
+// RestoreStates loads the saved states of all guilds, and offers to resume interrupted playback
+// in the text channel that it was started from
+func RestoreStates(client *discord.Client) {
+	paths, err := filepath.Glob(filepath.Join(stateSnapshotDirectory(), "*.json"))
+	if err != nil {
+		zap.S().Warnw("Failed to list saved states", "error", err)
+		return
+	}
+
+	for _, path := range paths {
+		guildId := strings.TrimSuffix(filepath.Base(path), ".json")
+		snapshot, err := loadStateSnapshot(path)
+		if err != nil {
+			zap.S().Warnw("Failed to load saved state", "guildId", guildId, "error", err)
+			continue
+		}
+
+		state := getGuildBotState(guildId)
+		state.mutex.Lock()
+		state.textChannelId = snapshot.TextChannelId
+		state.interrupted = snapshot.Playback
+		state.mutex.Unlock()
+		state.Queue.Append(snapshot.Queue...)
+		zap.S().Infow("Restored saved state", "guildId", guildId, "queueLength", len(snapshot.Queue), "interrupted", snapshot.Playback != nil)
+
+		if snapshot.Playback != nil && len(snapshot.TextChannelId) > 0 {
+			text := EmojiNeutral + "Playback of " + formatMediaItem(snapshot.Playback.Item.MediaItem) + " was interrupted by a restart. " +
+				"Use `.resume` to continue it in <#" + snapshot.Playback.VoiceChannelId + ">"
+			if len(snapshot.Queue) > 0 {
+				text += ", followed by **" + strconv.Itoa(len(snapshot.Queue)) + " items** in the queue"
+			}
+			client.SendMessage(snapshot.TextChannelId, text)
+		}
+	}
+}
+
+// ResumeCommand rejoins the voice channel of the playback that was interrupted by a restart,
+// and continues the media item at the position it was interrupted at
+func ResumeCommand(cmd discord.CommandBuffer, client *discord.Client) {
+	state := GetBotState(cmd.Message)
+	playback := state.interrupted
+	if playback == nil {
+		client.ReplyMessage(cmd.Message, EmojiFailed+"There is no interrupted playback to resume")
+		return
+	}
+
+	statusMsg := client.ReplyMessage(cmd.Message, EmojiLoading+"Resuming `"+playback.Item.Name+"`...")
+	nowPlaying := &NowPlaying{Item: playback.Item, Message: statusMsg, Autoplay: playback.Autoplay}
+	startPlayback(cmd, client, cmd.Message.GuildId, playback.VoiceChannelId, nowPlaying, playback.Position, 0)
+}
+
+// persist saves a snapshot of the state whenever it changes, and regularly while playing to keep the position current
+func (state *BotState) persist(queueChanges <-chan interface{}) {
+	ticker := time.NewTicker(snapshotPositionInterval)
+	defer ticker.Stop()
+
+	var saved []byte
+	for {
+		select {
+		case <-queueChanges:
+		case <-state.changes:
+		case <-ticker.C:
+		}
+
+		snapshot := state.snapshot()
+		data, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			zap.S().Errorw("Failed to encode state snapshot", "guildId", state.guildId, "error", err)
+			continue
+		}
+		if bytes.Equal(data, saved) {
+			continue
+		}
+
+		path := stateSnapshotPath(state.guildId)
+		if snapshot.Playback == nil && len(snapshot.Queue) == 0 {
+			err = os.Remove(path)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = writeFileAtomic(path, data)
+		}
+
+		if err != nil {
+			zap.S().Warnw("Failed to save state snapshot", "guildId", state.guildId, "error", err)
+			continue
+		}
+		saved = data
+	}
+}
+
+// snapshot captures the queue and the playing media item. An interrupted playback that was not resumed yet is kept.
+func (state *BotState) snapshot() stateSnapshot {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	snapshot := stateSnapshot{
+		TextChannelId: state.textChannelId,
+		Queue:         make([]QueueItem, 0),
+	}
+	for _, item := range state.Queue.Items() {
+		if isResumable(item.MediaItem) {
+			snapshot.Queue = append(snapshot.Queue, item)
+		}
+	}
+
+	if nowPlaying, encoder := state.NowPlaying, state.Encoder; state.isPlaying() {
+		if item := nowPlaying.queueItem(); isResumable(item.MediaItem) {
+			snapshot.Playback = &playbackSnapshot{
+				Item:           item,
+				Position:       encoder.Position().Truncate(time.Second),
+				VoiceChannelId: state.voiceChannelId,
+				Autoplay:       nowPlaying.Autoplay,
+			}
+		}
+	} else if state.interrupted != nil {
+		snapshot.Playback = state.interrupted
+	}
+	return snapshot
+}
+
+// isResumable checks whether a media item can still be played after a restart. Discord attachments are
+// played from signed URLs, which expire after a while.
+func isResumable(item ytapi.MediaItem) bool {
+	return item.Type != ytapi.MediaTypeFile || !isUrl(item.Url)
+}
+
+func loadStateSnapshot(path string) (stateSnapshot, error) {
+	var snapshot stateSnapshot
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &snapshot)
+	}
+	return snapshot, err
+}
+
+func stateSnapshotDirectory() string {
+	return filepath.Join(config.GetString(config.KeyDataDirectory), "state")
+}
+
+func stateSnapshotPath(guildId string) string {
+	return filepath.Join(stateSnapshotDirectory(), guildId+".json")
+}
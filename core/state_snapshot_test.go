@@ -0,0 +1,26 @@
+package core
+
+import (
+	"testing"
+	"ytbot/ytapi"
+)
+
+func TestSnapshotLeavesOutAttachments(t *testing.T) {
+	state := &BotState{}
+	state.Queue.Append(testItem("a", "1"), testItem("b", "1"))
+
+	attachment := testItem("", "1")
+	attachment.Name = "attachment"
+	attachment.Type = ytapi.MediaTypeFile
+	attachment.Url = "https://cdn.discordapp.com/attachments/1/2/song.mp3?ex=1&is=2&hm=3"
+	libraryFile := testItem("", "1")
+	libraryFile.Name = "library"
+	libraryFile.Type = ytapi.MediaTypeFile
+	libraryFile.Url = "/music/song.mp3"
+	state.Queue.Append(attachment, libraryFile)
+
+	// Attachment URLs expire, so they could not be played after a restart anymore
+	if ids := queueIds(state.snapshot().Queue); ids != "a b library" {
+		t.Errorf("expected the snapshot to contain `a b library`, got `%s`", ids)
+	}
+}
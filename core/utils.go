@@ -1,6 +1,11 @@
 package core
 
-import "golang.org/x/exp/constraints"
+import (
+	"fmt"
+	"golang.org/x/exp/constraints"
+	"time"
+	"ytbot/ytapi"
+)
 
 func min[T constraints.Ordered](a, b T) T {
 	if a < b {
@@ -15,3 +20,21 @@ func max[T constraints.Ordered](a, b T) T {
 	}
 	return b
 }
+
+func formatDuration(duration time.Duration) string {
+	seconds := int(duration.Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// formatMediaItem formats the name of a media item together with its duration, if known
+func formatMediaItem(item ytapi.MediaItem) string {
+	if item.IsLive {
+		return "`" + item.Name + "` (LIVE)"
+	} else if item.Duration > 0 {
+		return "`" + item.Name + "` (" + formatDuration(item.Duration) + ")"
+	}
+	return "`" + item.Name + "`"
+}
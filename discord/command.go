@@ -43,6 +43,13 @@ func (buf *CommandBuffer) GetString() string {
 	return str
 }
 
+func (buf *CommandBuffer) GetStringOrDefault(defaultVal string) string {
+	if buf.index >= len(buf.parts) {
+		return defaultVal
+	}
+	return buf.GetString()
+}
+
 func (buf *CommandBuffer) GetStringAll() string {
 	return strings.Join(buf.parts[buf.index:], " ")
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/buger/jsonparser"
 	"go.uber.org/zap"
 	"runtime"
+	"sync"
 	"ytbot/discord/utils"
 )
 
@@ -20,7 +21,13 @@ type Client struct {
 	cmdPrefix byte
 	userId    string
 
-	Guilds       map[string]GuildState
+	awaiters   []*messageAwaiter
+	awaitMutex sync.Mutex
+
+	// guilds is written by the gateway goroutine and read by command handlers, so it is guarded by guildsMutex
+	guilds      map[string]GuildState
+	guildsMutex sync.RWMutex
+
 	Commands     chan CommandBuffer
 	VoiceServers chan VoiceServer
 }
@@ -28,7 +35,7 @@ type Client struct {
 func NewClient(token string, commandPrefix byte) *Client {
 	return &Client{
 		authToken:    "Bot " + token,
-		Guilds:       make(map[string]GuildState),
+		guilds:       make(map[string]GuildState),
 		cmdPrefix:    commandPrefix,
 		Commands:     make(chan CommandBuffer, 25),
 		VoiceServers: make(chan VoiceServer),
@@ -93,12 +100,44 @@ func (client *Client) PostMessage(message Message) Message {
 }
 
 func (client *Client) GetVoiceClient(guildId string) *VoiceClient {
-	return client.Guilds[guildId].VoiceClient
+	client.guildsMutex.RLock()
+	defer client.guildsMutex.RUnlock()
+	return client.guilds[guildId].VoiceClient
+}
+
+// GetVoiceState returns the voice state of a user in a guild, and false if the user is not known to be in a voice channel
+func (client *Client) GetVoiceState(guildId string, userId string) (VoiceState, bool) {
+	client.guildsMutex.RLock()
+	defer client.guildsMutex.RUnlock()
+	state, ok := client.guilds[guildId].VoiceStates[userId]
+	return state, ok
+}
+
+// CountListeners returns the number of other users in the voice channel of the bot, or zero if it is not in one
+func (client *Client) CountListeners(guildId string) int {
+	client.guildsMutex.RLock()
+	defer client.guildsMutex.RUnlock()
+
+	voiceStates := client.guilds[guildId].VoiceStates
+	ownVoiceState, ok := voiceStates[client.userId]
+	if !ok || len(ownVoiceState.ChannelId) == 0 {
+		return 0
+	}
+
+	listeners := 0
+	for userId, state := range voiceStates {
+		if userId != client.userId && state.ChannelId == ownVoiceState.ChannelId {
+			listeners++
+		}
+	}
+	return listeners
 }
 
 func (client *Client) JoinVoiceChannel(guildId string, channelId string) (*VoiceClient, error) {
 	// Find guild
-	guild, ok := client.Guilds[guildId]
+	client.guildsMutex.RLock()
+	guild, ok := client.guilds[guildId]
+	client.guildsMutex.RUnlock()
 	if !ok {
 		return nil, errors.New("tried to join invalid guild")
 	}
@@ -122,7 +161,7 @@ func (client *Client) JoinVoiceChannel(guildId string, channelId string) (*Voice
 	voiceServer := <-client.VoiceServers
 
 	// Get own voice session
-	ownVoiceState, ok := guild.VoiceStates[client.userId]
+	ownVoiceState, ok := client.GetVoiceState(guildId, client.userId)
 	if !ok {
 		return nil, errors.New("could not get own voice state")
 	}
@@ -136,25 +175,32 @@ func (client *Client) JoinVoiceChannel(guildId string, channelId string) (*Voice
 	}
 
 	// Save voice client to guild
+	client.guildsMutex.Lock()
+	guild = client.guilds[guildId]
 	guild.VoiceClient = voiceClient
-	client.Guilds[guildId] = guild
+	client.guilds[guildId] = guild
+	client.guildsMutex.Unlock()
 
 	return voiceClient, nil
 }
 
 func (client *Client) LeaveVoiceChannel(guildId string) {
-	guild, ok := client.Guilds[guildId]
+	client.guildsMutex.Lock()
+	guild, ok := client.guilds[guildId]
 	if !ok {
+		client.guildsMutex.Unlock()
 		zap.S().Errorw("Failed to find guild while leaving voice channel", "guildId", guildId)
 		return
 	}
 
-	if guild.VoiceClient != nil {
-		guild.VoiceClient.Close()
-		guild.VoiceClient = nil
-	}
+	voiceClient := guild.VoiceClient
+	guild.VoiceClient = nil
+	client.guilds[guildId] = guild
+	client.guildsMutex.Unlock()
 
-	client.Guilds[guildId] = guild
+	if voiceClient != nil {
+		voiceClient.Close()
+	}
 
 	client.ws.Send(GatewayOpVoiceStateUpdate, VoiceStateLeave{
 		GuildId:   guildId,
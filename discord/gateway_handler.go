@@ -45,20 +45,29 @@ func (client *Client) handleEvent(in WsMessageIn) {
 			voiceStateMap[state.UserId] = state
 		}
 
-		client.Guilds[message.Id] = GuildState{
+		client.guildsMutex.Lock()
+		client.guilds[message.Id] = GuildState{
 			Id:          message.Id,
 			Name:        message.Name,
 			VoiceStates: voiceStateMap,
 		}
+		client.guildsMutex.Unlock()
 	case GatewayEventVoiceStateUpdate:
 		var state VoiceState
 		in.Unmarshal(&state)
 
-		client.Guilds[state.GuildId].VoiceStates[state.UserId] = state
+		client.guildsMutex.Lock()
+		if guild, ok := client.guilds[state.GuildId]; ok {
+			guild.VoiceStates[state.UserId] = state
+		}
+		client.guildsMutex.Unlock()
 	case GatewayEventMessageCreate:
 		var message Message
 		in.Unmarshal(&message)
 
+		if client.deliverAwaitedMessage(message) {
+			return
+		}
 		if len(message.Content) > 0 && message.Content[0] == client.cmdPrefix {
 			client.Commands <- NewCommandBuffer(message)
 		}
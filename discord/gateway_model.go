@@ -90,12 +90,21 @@ type User struct {
 }
 
 type Message struct {
-	Id        string `json:"id"`
-	Content   string `json:"content"`
-	Author    User   `json:"author"`
-	GuildId   string `json:"guild_id"`
-	ChannelId string `json:"channel_id"`
-	Nonce     string `json:"nonce"`
+	Id          string       `json:"id"`
+	Content     string       `json:"content"`
+	Author      User         `json:"author"`
+	GuildId     string       `json:"guild_id"`
+	ChannelId   string       `json:"channel_id"`
+	Nonce       string       `json:"nonce"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+type Attachment struct {
+	Id          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Url         string `json:"url"`
 }
 
 type VoiceState struct {
@@ -0,0 +1,71 @@
+package discord
+
+import (
+	"time"
+)
+
+type messageAwaiter struct {
+	channelId string
+	userId    string
+	filter    func(message Message) bool
+	result    chan Message
+}
+
+// AwaitMessage waits for the next message of a user in a channel that is accepted by the filter.
+// The message is consumed and not handled as a command. It returns false if no such message
+// arrives before the timeout.
+func (client *Client) AwaitMessage(channelId string, userId string, filter func(message Message) bool, timeout time.Duration) (Message, bool) {
+	awaiter := &messageAwaiter{
+		channelId: channelId,
+		userId:    userId,
+		filter:    filter,
+		result:    make(chan Message, 1),
+	}
+
+	client.awaitMutex.Lock()
+	client.awaiters = append(client.awaiters, awaiter)
+	client.awaitMutex.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case message := <-awaiter.result:
+		return message, true
+	case <-timer.C:
+	}
+
+	if client.removeAwaiter(awaiter) {
+		return Message{}, false
+	}
+	// The message arrived while the timeout fired
+	return <-awaiter.result, true
+}
+
+// deliverAwaitedMessage passes the message to the first matching awaiter, and returns whether it was consumed
+func (client *Client) deliverAwaitedMessage(message Message) bool {
+	client.awaitMutex.Lock()
+	defer client.awaitMutex.Unlock()
+
+	for idx, awaiter := range client.awaiters {
+		if awaiter.channelId == message.ChannelId && awaiter.userId == message.Author.Id && awaiter.filter(message) {
+			client.awaiters = append(client.awaiters[:idx:idx], client.awaiters[idx+1:]...)
+			awaiter.result <- message
+			return true
+		}
+	}
+	return false
+}
+
+func (client *Client) removeAwaiter(awaiter *messageAwaiter) bool {
+	client.awaitMutex.Lock()
+	defer client.awaitMutex.Unlock()
+
+	for idx, candidate := range client.awaiters {
+		if candidate == awaiter {
+			client.awaiters = append(client.awaiters[:idx:idx], client.awaiters[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -107,6 +107,10 @@ func (stream *VoiceStream) OnFailed() {
 	stream.playing = false
 }
 
+func (stream *VoiceStream) SetSpeaking(speaking bool) {
+	stream.parent.sendSpeaking(speaking)
+}
+
 func (stream *VoiceStream) encryptAudio(audioFrame []byte, nonceBytes []byte) []byte {
 	var secretKey [32]byte
 	copy(secretKey[:], stream.key)
@@ -0,0 +1,122 @@
+package library
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"ytbot/codec"
+	"ytbot/config"
+	"ytbot/ytapi"
+)
+
+var ErrNotConfigured = errors.New("no local music library is configured")
+
+var supportedExtensions = []string{".mp3", ".flac", ".ogg", ".opus", ".wav", ".m4a", ".aac", ".wma", ".webm", ".mp4", ".mkv", ".mov"}
+
+// IsSupportedFile checks whether a file name has the extension of a common audio or video format
+func IsSupportedFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, candidate := range supportedExtensions {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// Find searches the local library for a query. If the query names a directory, all of its files
+// are returned. Otherwise, the first file whose relative path contains every word of the query is returned.
+func Find(query string) ([]string, error) {
+	root := config.GetString(config.KeyLibraryDirectory)
+	if len(root) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	files, err := listFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	query = filepath.ToSlash(strings.Trim(strings.TrimSpace(query), "/"))
+	dirPrefix := strings.ToLower(query) + "/"
+	dirMatches := make([]string, 0)
+	for _, file := range files {
+		if strings.EqualFold(file, query) {
+			return []string{filepath.Join(root, file)}, nil
+		}
+		if strings.HasPrefix(strings.ToLower(file), dirPrefix) {
+			dirMatches = append(dirMatches, filepath.Join(root, file))
+		}
+	}
+	if len(dirMatches) > 0 {
+		return dirMatches, nil
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	for _, file := range files {
+		if containsAll(strings.ToLower(file), words) {
+			return []string{filepath.Join(root, file)}, nil
+		}
+	}
+
+	return []string{}, nil
+}
+
+// LoadMediaItem creates a MediaItem for a local file or direct media URL, reading its metadata using ffprobe
+func LoadMediaItem(location string, fallbackName string) (ytapi.MediaItem, error) {
+	probe, err := codec.Probe(location)
+	if err != nil {
+		return ytapi.MediaItem{}, err
+	}
+
+	name := fallbackName
+	if len(probe.Title) > 0 && len(probe.Artist) > 0 {
+		name = probe.Artist + " - " + probe.Title
+	} else if len(probe.Title) > 0 {
+		name = probe.Title
+	}
+
+	return ytapi.MediaItem{
+		Name:     name,
+		Url:      location,
+		Type:     ytapi.MediaTypeFile,
+		Duration: probe.Duration,
+	}, nil
+}
+
+func listFiles(root string) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !IsSupportedFile(entry.Name()) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(relPath))
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("the local music library directory does not exist")
+	}
+
+	sort.Strings(files)
+	return files, err
+}
+
+func containsAll(str string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(str, word) {
+			return false
+		}
+	}
+	return len(words) > 0
+}
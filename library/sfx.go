@@ -0,0 +1,49 @@
+package library
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"ytbot/config"
+)
+
+var ErrNoSoundEffects = errors.New("no sound effect directory is configured")
+
+// ListSoundEffects returns the names of all sound effects, which are the file names without extension
+func ListSoundEffects() ([]string, error) {
+	root := config.GetString(config.KeySfxDirectory)
+	if len(root) == 0 {
+		return nil, ErrNoSoundEffects
+	}
+
+	files, err := listFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(file, filepath.Ext(file)))
+	}
+	return names, nil
+}
+
+// FindSoundEffect returns the path of the sound effect with the given name, or an empty string if it does not exist
+func FindSoundEffect(name string) (string, error) {
+	root := config.GetString(config.KeySfxDirectory)
+	if len(root) == 0 {
+		return "", ErrNoSoundEffects
+	}
+
+	files, err := listFiles(root)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		if strings.EqualFold(strings.TrimSuffix(file, filepath.Ext(file)), name) {
+			return filepath.Join(root, file), nil
+		}
+	}
+	return "", nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"go.uber.org/zap"
 	"ytbot/config"
 	"ytbot/core"
@@ -14,20 +15,20 @@ func main() {
 
 	zap.S().Infoln("Starting YTBot")
 
-	err := ytdlp.EnsurePresent()
+	err := config.CheckRequired()
 	if err != nil {
-		zap.S().Fatalw("Failed to ensure a valid yt-dlp is present",
-			"error", err,
-		)
+		zap.S().Fatalw("Invalid configuration", "error", err)
 	}
 
-	err = ytdlp.CheckForUpdates()
+	err = ytdlp.EnsurePresent(context.Background())
 	if err != nil {
-		zap.S().Fatalw("Failed to check for yt-dlp updates",
+		zap.S().Fatalw("Failed to ensure a valid yt-dlp is present",
 			"error", err,
 		)
 	}
 
+	ytdlp.StartUpdateChecker()
+
 	discordClient := discord.NewClient(config.GetString(config.KeyAuthToken), '.')
 	discordClient.AddIntent(discord.IntentGuilds)
 	discordClient.AddIntent(discord.IntentVoiceStates)
@@ -42,8 +43,8 @@ func main() {
 		)
 	}
 
+	core.RestoreStates(discordClient)
+
 	zap.S().Debugln("Starting command handler")
-	for cmd := range discordClient.Commands {
-		core.HandleCommand(cmd, discordClient)
-	}
+	core.RunCommandLoop(discordClient)
 }
@@ -0,0 +1,46 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+	"ytbot/codec"
+	"ytbot/ytapi"
+)
+
+const probeTimeout = 10 * time.Second
+
+var ErrNotAStream = errors.New("the URL does not point to an audio stream")
+
+// LoadMediaItem connects to an internet radio stream to check that it is valid and to read its name
+func LoadMediaItem(streamUrl string) (ytapi.MediaItem, error) {
+	urlVal, err := url.Parse(streamUrl)
+	if err != nil || (urlVal.Scheme != "http" && urlVal.Scheme != "https") {
+		return ytapi.MediaItem{}, ErrNotAStream
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	resp, info, err := codec.OpenStream(ctx, streamUrl)
+	if err != nil {
+		return ytapi.MediaItem{}, err
+	}
+	_ = resp.Body.Close()
+
+	if !info.IsStream() {
+		return ytapi.MediaItem{}, ErrNotAStream
+	}
+
+	name := info.Name
+	if len(name) == 0 {
+		name = urlVal.Hostname()
+	}
+
+	return ytapi.MediaItem{
+		Name: name,
+		Url:  streamUrl,
+		Type: ytapi.MediaTypeStream,
+	}, nil
+}
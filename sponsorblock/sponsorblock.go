@@ -0,0 +1,137 @@
+package sponsorblock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"ytbot/config"
+)
+
+const requestTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// Segment is a part of a video that was submitted to SponsorBlock
+type Segment struct {
+	Category string
+	Start    time.Duration
+	End      time.Duration
+}
+
+type apiSegment struct {
+	Segment    []float64 `json:"segment"`
+	Category   string    `json:"category"`
+	ActionType string    `json:"actionType"`
+}
+
+// Enabled checks whether any categories of segments should be skipped
+func Enabled() bool {
+	return len(Categories()) > 0
+}
+
+// Categories returns the configured categories of segments that are skipped, like `sponsor` or `music_offtopic`
+func Categories() []string {
+	categories := make([]string, 0)
+	for _, category := range strings.Split(config.GetString(config.KeySponsorBlockCategories), ",") {
+		if category = strings.TrimSpace(category); len(category) > 0 {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// GetSegments loads the segments of the configured categories for a YouTube video, ordered by their start
+func GetSegments(videoId string) ([]Segment, error) {
+	enabledCategories := Categories()
+	categories, err := json.Marshal(enabledCategories)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("videoID", videoId)
+	query.Set("categories", string(categories))
+	query.Set("actionType", "skip")
+	reqUrl := strings.TrimSuffix(config.GetString(config.KeySponsorBlockUrl), "/") + "/api/skipSegments?" + query.Encode()
+
+	resp, err := httpClient.Get(reqUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// SponsorBlock reports videos without segments as not found
+		return []Segment{}, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	var apiSegments []apiSegment
+	err = json.NewDecoder(resp.Body).Decode(&apiSegments)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]Segment, 0, len(apiSegments))
+	for _, segment := range apiSegments {
+		if len(segment.Segment) != 2 || (len(segment.ActionType) > 0 && segment.ActionType != "skip") {
+			continue
+		}
+		// Mirrors of the API do not always honour the requested categories
+		if !containsCategory(enabledCategories, segment.Category) {
+			continue
+		}
+		segments = append(segments, Segment{
+			Category: segment.Category,
+			Start:    secondsToDuration(segment.Segment[0]),
+			End:      secondsToDuration(segment.Segment[1]),
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+	return segments, nil
+}
+
+// Description returns a readable name of the category of the segment
+func (segment Segment) Description() string {
+	switch segment.Category {
+	case "sponsor":
+		return "sponsor"
+	case "selfpromo":
+		return "self-promotion"
+	case "interaction":
+		return "interaction reminder"
+	case "intro":
+		return "intro"
+	case "outro":
+		return "outro"
+	case "preview":
+		return "preview"
+	case "music_offtopic":
+		return "non-music section"
+	case "filler":
+		return "filler"
+	default:
+		return segment.Category
+	}
+}
+
+func containsCategory(categories []string, category string) bool {
+	for _, candidate := range categories {
+		if candidate == category {
+			return true
+		}
+	}
+	return false
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
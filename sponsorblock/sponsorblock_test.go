@@ -0,0 +1,131 @@
+package sponsorblock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+	"ytbot/config"
+)
+
+// startSponsorBlockServer serves the response for all requests of segments, and records the query of the last request
+func startSponsorBlockServer(t *testing.T, status int, response string) *url.Values {
+	t.Helper()
+	lastQuery := &url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/skipSegments" {
+			http.NotFound(w, r)
+			return
+		}
+		*lastQuery = r.URL.Query()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	config.Set(config.KeySponsorBlockUrl, server.URL+"/")
+	return lastQuery
+}
+
+func TestGetSegmentsParsesSegments(t *testing.T) {
+	config.Set(config.KeySponsorBlockCategories, "sponsor, selfpromo")
+	query := startSponsorBlockServer(t, http.StatusOK, `[
+		{"segment": [120.5, 150], "category": "selfpromo", "actionType": "skip", "UUID": "b"},
+		{"segment": [10, 42.25], "category": "sponsor", "actionType": "skip", "UUID": "a"},
+		{"segment": [60], "category": "sponsor", "actionType": "skip"},
+		{"segment": [70, 80], "category": "sponsor", "actionType": "mute"},
+		{"segment": [90, 95], "category": "sponsor"}
+	]`)
+
+	segments, err := GetSegments("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Segment{
+		{Category: "sponsor", Start: 10 * time.Second, End: 42250 * time.Millisecond},
+		{Category: "sponsor", Start: 90 * time.Second, End: 95 * time.Second},
+		{Category: "selfpromo", Start: 120500 * time.Millisecond, End: 150 * time.Second},
+	}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("expected %+v, got %+v", expected, segments)
+	}
+
+	if query.Get("videoID") != "dQw4w9WgXcQ" || query.Get("actionType") != "skip" {
+		t.Errorf("unexpected query %s", query.Encode())
+	}
+	var categories []string
+	err = json.Unmarshal([]byte(query.Get("categories")), &categories)
+	if err != nil || !reflect.DeepEqual(categories, []string{"sponsor", "selfpromo"}) {
+		t.Errorf("expected the configured categories to be requested, got %s", query.Get("categories"))
+	}
+}
+
+func TestGetSegmentsFiltersCategories(t *testing.T) {
+	config.Set(config.KeySponsorBlockCategories, "intro")
+	startSponsorBlockServer(t, http.StatusOK, `[
+		{"segment": [0, 5], "category": "intro", "actionType": "skip"},
+		{"segment": [10, 20], "category": "sponsor", "actionType": "skip"},
+		{"segment": [30, 40], "category": "music_offtopic", "actionType": "skip"}
+	]`)
+
+	segments, err := GetSegments("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Segment{{Category: "intro", Start: 0, End: 5 * time.Second}}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("expected %+v, got %+v", expected, segments)
+	}
+}
+
+func TestGetSegmentsOfVideoWithoutSegments(t *testing.T) {
+	config.Set(config.KeySponsorBlockCategories, "sponsor")
+	startSponsorBlockServer(t, http.StatusNotFound, "Not Found")
+
+	segments, err := GetSegments("dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("expected no segments, got %+v", segments)
+	}
+}
+
+func TestGetSegmentsFailsOnServerError(t *testing.T) {
+	config.Set(config.KeySponsorBlockCategories, "sponsor")
+	startSponsorBlockServer(t, http.StatusInternalServerError, "")
+
+	_, err := GetSegments("dQw4w9WgXcQ")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestCategories(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+	}{
+		{"", []string{}},
+		{" , ", []string{}},
+		{"sponsor", []string{"sponsor"}},
+		{"sponsor, selfpromo,,music_offtopic ", []string{"sponsor", "selfpromo", "music_offtopic"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			config.Set(config.KeySponsorBlockCategories, test.value)
+			if categories := Categories(); !reflect.DeepEqual(categories, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, categories)
+			}
+			if Enabled() != (len(test.expected) > 0) {
+				t.Errorf("expected Enabled to be %v", len(test.expected) > 0)
+			}
+		})
+	}
+}
@@ -3,9 +3,15 @@ package ytapi
 import (
 	"errors"
 	"net/url"
+	"strings"
+	"ytbot/config"
 )
 
-func LoadMediaItems(query string) ([]MediaItem, error) {
+var ErrUnsupportedQuery = errors.New("this query is not supported")
+
+// LoadMediaItems loads the videos of a YouTube link, or the first result of a search query.
+// Progress of loading long playlists is reported to onProgress, which may be nil.
+func LoadMediaItems(query string, onProgress ProgressFunc) ([]MediaItem, error) {
 	urlVal, err := url.Parse(query)
 	if err != nil || len(urlVal.Hostname()) == 0 {
 		// not a 'real' url, could be search query
@@ -19,26 +25,64 @@ func LoadMediaItems(query string) ([]MediaItem, error) {
 		} else {
 			return []MediaItem{results[0]}, nil
 		}
-	} else if urlVal.Query().Has("list") {
-		items, err := GetPlaylistItems(urlVal.Query().Get("list"))
+	}
+
+	ref, ok := ParseReference(query)
+	if !ok {
+		return nil, ErrUnsupportedQuery
+	}
+
+	if IsMixPlaylist(ref.PlaylistId) {
+		// Mixes are generated from a video, so they are loaded regardless of the preference
+		items, err := GetMixItems(ref.PlaylistId, ref.VideoId)
 		if err != nil {
 			return nil, err
-		} else {
-			return items, nil
 		}
-	} else if urlVal.Query().Has("v") {
-		video, err := GetVideo(urlVal.Query().Get("v"))
+		return skipToVideo(items, ref), nil
+	} else if len(ref.PlaylistId) > 0 && (len(ref.VideoId) == 0 || prefersPlaylist()) {
+		items, err := GetPlaylistItems(ref.PlaylistId, onProgress)
 		if err != nil {
 			return nil, err
 		}
-		return []MediaItem{video}, nil
-	} else if urlVal.Hostname() == "youtu.be" {
-		video, err := GetVideo(urlVal.Path[1:])
-		if err != nil {
-			return nil, err
+		return skipToVideo(items, ref), nil
+	}
+
+	video, err := GetVideo(ref.VideoId)
+	if err != nil {
+		return nil, err
+	}
+	video.StartOffset = ref.StartOffset
+	return []MediaItem{video}, nil
+}
+
+// prefersPlaylist checks whether links to a video within a playlist should load the whole playlist
+func prefersPlaylist() bool {
+	return strings.EqualFold(config.GetString(config.KeyVideoListPreference), "playlist")
+}
+
+// skipToVideo removes all items of a playlist before the video that the reference points to
+func skipToVideo(items []MediaItem, ref Reference) []MediaItem {
+	if len(ref.VideoId) == 0 {
+		return items
+	}
+
+	start := -1
+	for idx, item := range items {
+		if item.Id == ref.VideoId {
+			start = idx
+			break
 		}
-		return []MediaItem{video}, nil
-	} else {
-		return nil, errors.New("this query is not supported")
 	}
+	if start < 0 && ref.Index > 0 && ref.Index <= len(items) {
+		start = ref.Index - 1
+	}
+	if start < 0 {
+		return items
+	}
+
+	items = items[start:]
+	if items[0].Id == ref.VideoId {
+		items[0].StartOffset = ref.StartOffset
+	}
+	return items
 }
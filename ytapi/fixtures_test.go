@@ -0,0 +1,164 @@
+package ytapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/buger/jsonparser"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"ytbot/config"
+)
+
+// The fixtures in testdata are recorded with `go run ./cmd/ytfixtures refresh`
+var updateGolden = flag.Bool("update", false, "rewrite the golden files from the recorded responses")
+
+// fixtureKeyFields are the fields of InnerTube requests that identify the recorded response
+var fixtureKeyFields = []string{"query", "browseId", "continuation", "videoId"}
+
+type fixtureCase struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Input string `json:"input"`
+	// Renderers are the keys of the parsed items, which are renamed to simulate a changed layout
+	Renderers []string `json:"renderers"`
+}
+
+func TestFixtures(t *testing.T) {
+	cases := make([]fixtureCase, 0)
+	readFixture(t, "cases.json", &cases)
+
+	// Playlists are always loaded completely, so that the golden files do not depend on the configuration
+	config.Set(config.KeyPlaylistLimit, "0")
+
+	for _, fixture := range cases {
+		t.Run(fixture.Name, func(t *testing.T) {
+			responses := make(map[string]json.RawMessage)
+			readFixture(t, fixture.Name+".responses.json", &responses)
+
+			server := httptest.NewServer(replayResponses(responses))
+			defer server.Close()
+			config.Set(config.KeyYouTubeBaseUrl, server.URL)
+
+			items, err := runFixture(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := json.MarshalIndent(items, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, '\n')
+
+			goldenPath := filepath.Join("testdata", fixture.Name+".golden.json")
+			if *updateGolden {
+				err = os.WriteFile(goldenPath, actual, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("parsed items differ from %s, run the test with -update after an intended parser change\n%s", goldenPath, actual)
+			}
+		})
+	}
+}
+
+func TestFixturesDetectLayoutChanges(t *testing.T) {
+	cases := make([]fixtureCase, 0)
+	readFixture(t, "cases.json", &cases)
+	config.Set(config.KeyPlaylistLimit, "0")
+
+	for _, fixture := range cases {
+		t.Run(fixture.Name, func(t *testing.T) {
+			responses := make(map[string]json.RawMessage)
+			readFixture(t, fixture.Name+".responses.json", &responses)
+			for key, response := range responses {
+				for _, renderer := range fixture.Renderers {
+					response = bytes.ReplaceAll(response, []byte(`"`+renderer+`"`), []byte(`"unknown`+renderer+`"`))
+				}
+				responses[key] = response
+			}
+
+			server := httptest.NewServer(replayResponses(responses))
+			defer server.Close()
+			config.Set(config.KeyYouTubeBaseUrl, server.URL)
+
+			items, err := runFixture(fixture)
+			if !errors.Is(err, ErrLayoutChanged) {
+				t.Errorf("expected ErrLayoutChanged without %v, got %v with %d items", fixture.Renderers, err, len(items))
+			}
+		})
+	}
+}
+
+func runFixture(fixture fixtureCase) ([]MediaItem, error) {
+	switch fixture.Kind {
+	case "search":
+		return Search(fixture.Input)
+	case "playlist":
+		return GetPlaylistItems(fixture.Input, nil)
+	case "mix":
+		return GetMixItems(fixture.Input, "")
+	case "related":
+		return GetRelatedVideos(fixture.Input)
+	case "video":
+		item, err := GetVideo(fixture.Input)
+		return []MediaItem{item}, err
+	default:
+		return nil, fmt.Errorf("unknown fixture kind `%s`", fixture.Kind)
+	}
+}
+
+// replayResponses serves the recorded responses, keyed by the endpoint and the main parameter of the request
+func replayResponses(responses map[string]json.RawMessage) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		key := path.Base(r.URL.Path)
+		for _, field := range fixtureKeyFields {
+			if value, err := jsonparser.GetString(body, field); err == nil {
+				key += ":" + value
+				break
+			}
+		}
+
+		response, ok := responses[key]
+		if !ok {
+			http.Error(w, "no recorded response for "+key, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(response)
+	})
+}
+
+func readFixture(t *testing.T, name string, value interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(data, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,20 +1,28 @@
 package ytapi
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
-func Get(url string) (string, error) {
-	resp, err := http.Get(url)
+// consentCookie skips the cookie consent page that YouTube shows to clients from the EU
+const consentCookie = "SOCS=CAI; CONSENT=YES+1"
+
+// httpClient gives up on requests that take longer than the timeout, including reading the response
+var httpClient = &http.Client{Timeout: 20 * time.Second}
+
+func send(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %d for %s", resp.StatusCode, req.URL.Path)
 	}
 
-	return string(body), nil
+	return io.ReadAll(resp.Body)
 }
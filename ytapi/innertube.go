@@ -0,0 +1,105 @@
+package ytapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/buger/jsonparser"
+	"net/http"
+	"strings"
+	"ytbot/config"
+)
+
+// ErrLayoutChanged is returned if a response does not have the expected structure anymore
+var ErrLayoutChanged = errors.New("the layout of the YouTube response changed")
+
+const (
+	defaultClientName    = "WEB"
+	defaultClientVersion = "2.20240101.00.00"
+)
+
+// InnerTube is a client for the internal `youtubei/v1` API that the YouTube website uses.
+// The responses contain the same renderers as the `ytInitialData` of the website.
+type InnerTube struct {
+	BaseUrl       string
+	ClientName    string
+	ClientVersion string
+	Language      string
+}
+
+// NewInnerTube creates a client for the configured YouTube base URL
+func NewInnerTube() *InnerTube {
+	return &InnerTube{
+		BaseUrl:       strings.TrimSuffix(config.GetString(config.KeyYouTubeBaseUrl), "/"),
+		ClientName:    defaultClientName,
+		ClientVersion: defaultClientVersion,
+		Language:      "en",
+	}
+}
+
+func (it *InnerTube) Search(query string) ([]byte, error) {
+	return it.call("search", map[string]interface{}{
+		"query": query,
+	})
+}
+
+func (it *InnerTube) Browse(browseId string) ([]byte, error) {
+	return it.call("browse", map[string]interface{}{
+		"browseId": browseId,
+	})
+}
+
+func (it *InnerTube) BrowseContinuation(token string) ([]byte, error) {
+	return it.call("browse", map[string]interface{}{
+		"continuation": token,
+	})
+}
+
+// Next loads the watch page of a video, which contains its details, related videos and the playlist
+// it is played in. The playlist id may be empty.
+func (it *InnerTube) Next(videoId string, playlistId string) ([]byte, error) {
+	params := map[string]interface{}{
+		"videoId": videoId,
+	}
+	if len(playlistId) > 0 {
+		params["playlistId"] = playlistId
+	}
+	return it.call("next", params)
+}
+
+func (it *InnerTube) Player(videoId string) ([]byte, error) {
+	return it.call("player", map[string]interface{}{
+		"videoId": videoId,
+	})
+}
+
+func (it *InnerTube) call(endpoint string, params map[string]interface{}) ([]byte, error) {
+	params["context"] = map[string]interface{}{
+		"client": map[string]string{
+			"clientName":    it.ClientName,
+			"clientVersion": it.ClientVersion,
+			"hl":            it.Language,
+		},
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", it.BaseUrl+"/youtubei/v1/"+endpoint+"?prettyPrint=false", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cookie", consentCookie)
+
+	return send(req)
+}
+
+// layoutError reports missing keys as ErrLayoutChanged, and returns all other errors unchanged
+func layoutError(err error) error {
+	if errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return ErrLayoutChanged
+	}
+	return err
+}
@@ -2,29 +2,103 @@ package ytapi
 
 import (
 	"github.com/buger/jsonparser"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func FindJsonData(data string, left string, right string) string {
-	out := data[strings.Index(data, left+"{")+len(left):]
-	out = out[:strings.Index(out, right)]
-	return out
-}
-
-func FindYouTubeData(data string) string {
-	return FindJsonData(data, "var ytInitialData = ", ";</script>")
-}
-
 func VideoRendererToMediaItem(videoRenderer []byte, fallbackId string) MediaItem {
 	id, _ := jsonparser.GetString(videoRenderer, "videoId")
-	name, _ := jsonparser.GetString(videoRenderer, "title", "runs", "[0]", "text")
+	name := findFirstString(videoRenderer, [][]string{{"title", "runs", "[0]", "text"}, {"title", "simpleText"}})
 
 	if len(id) == 0 {
 		id = fallbackId
 	}
 
 	return MediaItem{
-		Name: name,
-		Url:  "https://youtube.com/watch?v=" + id,
+		Id:        id,
+		Name:      name,
+		Url:       "https://youtube.com/watch?v=" + id,
+		Source:    SourceYouTube,
+		Duration:  findDuration(videoRenderer),
+		Uploader:  findFirstString(videoRenderer, [][]string{{"ownerText", "runs", "[0]", "text"}, {"longBylineText", "runs", "[0]", "text"}, {"shortBylineText", "runs", "[0]", "text"}}),
+		Thumbnail: findThumbnail(videoRenderer),
+		ViewCount: parseCount(findFirstString(videoRenderer, [][]string{{"viewCountText", "simpleText"}, {"viewCount", "videoViewCountRenderer", "viewCount", "simpleText"}})),
+		IsLive:    isLiveRenderer(videoRenderer),
+	}
+}
+
+func findFirstString(data []byte, paths [][]string) string {
+	for _, path := range paths {
+		value, err := jsonparser.GetString(data, path...)
+		if err == nil && len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+func findDuration(videoRenderer []byte) time.Duration {
+	if seconds, err := jsonparser.GetString(videoRenderer, "lengthSeconds"); err == nil {
+		if value, err := strconv.Atoi(seconds); err == nil {
+			return time.Duration(value) * time.Second
+		}
+	}
+
+	lengthText := findFirstString(videoRenderer, [][]string{{"lengthText", "simpleText"}, {"lengthText", "runs", "[0]", "text"}})
+	return ParseTimestamp(lengthText)
+}
+
+func findThumbnail(videoRenderer []byte) string {
+	thumbnail := ""
+	_, _ = jsonparser.ArrayEach(videoRenderer, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		if url, err := jsonparser.GetString(value, "url"); err == nil {
+			thumbnail = url
+		}
+	}, "thumbnail", "thumbnails")
+	return thumbnail
+}
+
+func isLiveRenderer(videoRenderer []byte) bool {
+	if isLive, err := jsonparser.GetBoolean(videoRenderer, "viewCount", "videoViewCountRenderer", "isLive"); err == nil && isLive {
+		return true
+	}
+
+	live := false
+	_, _ = jsonparser.ArrayEach(videoRenderer, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		style, _ := jsonparser.GetString(value, "metadataBadgeRenderer", "style")
+		if style == "BADGE_STYLE_TYPE_LIVE_NOW" {
+			live = true
+		}
+	}, "badges")
+	return live
+}
+
+// ParseTimestamp parses timestamps like `4:20` or `1:02:03`, returning zero if the timestamp is invalid
+func ParseTimestamp(timestamp string) time.Duration {
+	if len(timestamp) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, part := range strings.Split(timestamp, ":") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return 0
+		}
+		total = total*60 + time.Duration(value)
+	}
+	return total * time.Second
+}
+
+// parseCount reads the number from texts like `1,234,567 views` or `812 videos`
+func parseCount(text string) int64 {
+	digits := strings.Builder{}
+	for _, char := range text {
+		if char >= '0' && char <= '9' {
+			digits.WriteRune(char)
+		}
 	}
+	count, _ := strconv.ParseInt(digits.String(), 10, 64)
+	return count
 }
@@ -1,6 +1,54 @@
 package ytapi
 
+import "time"
+
+// MediaType identifies where a MediaItem is played from
+type MediaType int
+
+const (
+	MediaTypeYouTube MediaType = iota
+	MediaTypeFile
+	MediaTypeStream
+	// MediaTypeExtractor items come from any other site supported by yt-dlp
+	MediaTypeExtractor
+)
+
+// SourceYouTube is the source of items loaded from YouTube, named like the yt-dlp extractor
+const SourceYouTube = "Youtube"
+
 type MediaItem struct {
-	Name string
-	Url  string
+	Id     string
+	Name   string
+	Url    string
+	Type   MediaType
+	Source string
+	// MixId is the id of the mix playlist that the item was loaded from, which is continued after it
+	MixId       string
+	Duration    time.Duration
+	StartOffset time.Duration
+	// EndOffset ends playback before the end of the media, for example after a chapter. It is zero otherwise
+	EndOffset    time.Duration
+	Uploader     string
+	Thumbnail    string
+	ViewCount    int64
+	IsLive       bool
+	Chapters     []Chapter
+	AudioFormats []AudioFormat
+}
+
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+type AudioFormat struct {
+	Id      string
+	Codec   string
+	Bitrate float64
+}
+
+// HasMetadata checks whether the details of the item are known, or whether only its name and URL were loaded
+func (item *MediaItem) HasMetadata() bool {
+	return item.Duration > 0 || item.IsLive || item.Type == MediaTypeFile || item.Type == MediaTypeStream
 }
@@ -0,0 +1,64 @@
+package ytapi
+
+import (
+	"errors"
+	"github.com/buger/jsonparser"
+	"strings"
+)
+
+var ErrNoMixSeed = errors.New("the mix does not contain the id of its seed video")
+
+// IsMixPlaylist checks whether the playlist is an automatically generated mix, like `RD<video id>`.
+// Mixes are endless and can only be loaded through the playlist panel of the watch page.
+func IsMixPlaylist(playlistId string) bool {
+	// Album playlists of YouTube Music also start with RD, but are regular playlists
+	return strings.HasPrefix(playlistId, "RD") && !strings.HasPrefix(playlistId, "RDCLAK")
+}
+
+// GetMixItems loads the videos of a mix that are shown in the playlist panel next to the video.
+// The panel only contains a window of the mix, so mixes are continued by loading the panel next to
+// their last video. If the video id is empty, the mix starts at the video it was seeded from.
+func GetMixItems(playlistId string, videoId string) ([]MediaItem, error) {
+	if len(videoId) == 0 {
+		videoId = mixSeedVideo(playlistId)
+		if len(videoId) == 0 {
+			return nil, ErrNoMixSeed
+		}
+	}
+
+	data, err := NewInnerTube().Next(videoId, playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MediaItem, 0)
+	entries := 0
+	_, err = jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		entries++
+		videoRenderer, dataType, _, _ := jsonparser.Get(value, "playlistPanelVideoRenderer")
+		if dataType == jsonparser.Object {
+			item := VideoRendererToMediaItem(videoRenderer, "")
+			item.MixId = playlistId
+			results = append(results, item)
+		}
+	}, "contents", "twoColumnWatchNextResults", "playlist", "playlist", "contents")
+	if err != nil {
+		return nil, layoutError(err)
+	} else if len(results) == 0 && entries > 0 {
+		return nil, ErrLayoutChanged
+	}
+
+	return results, nil
+}
+
+// mixSeedVideo returns the id of the video that a mix like `RD<video id>` or `RDAMVM<video id>` was seeded from
+func mixSeedVideo(playlistId string) string {
+	if len(playlistId) < 11 {
+		return ""
+	}
+	videoId := playlistId[len(playlistId)-11:]
+	if !videoIdPattern.MatchString(videoId) {
+		return ""
+	}
+	return videoId
+}
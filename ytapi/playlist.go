@@ -2,25 +2,106 @@ package ytapi
 
 import (
 	"github.com/buger/jsonparser"
-	"net/url"
+	"ytbot/config"
 )
 
-func GetPlaylistItems(playlistId string) ([]MediaItem, error) {
-	reqUrl := "https://youtube.com/playlist?list=" + url.QueryEscape(playlistId)
-	body, err := Get(reqUrl)
+// ProgressFunc is called while a playlist is loaded with the number of loaded items, and the number of
+// items that will be loaded in total. The total is 0 if it is not known.
+type ProgressFunc func(loaded int, total int)
+
+// GetPlaylistItems loads the videos of a playlist, following its continuations until the playlist
+// is exhausted or the configured limit is reached
+func GetPlaylistItems(playlistId string, onProgress ProgressFunc) ([]MediaItem, error) {
+	innerTube := NewInnerTube()
+	data, err := innerTube.Browse("VL" + playlistId)
 	if err != nil {
 		return nil, err
 	}
 
-	ytData := FindYouTubeData(body)
+	limit := config.GetInt(config.KeyPlaylistLimit)
+	total := findPlaylistLength(data)
+	if limit > 0 && total > limit {
+		total = limit
+	}
+
+	contents, _, _, err := jsonparser.Get(data, "contents", "twoColumnBrowseResultsRenderer", "tabs", "[0]", "tabRenderer", "content", "sectionListRenderer", "contents", "[0]", "itemSectionRenderer", "contents", "[0]", "playlistVideoListRenderer", "contents")
+	if err != nil {
+		return nil, layoutError(err)
+	}
 
 	results := make([]MediaItem, 0)
-	_, err = jsonparser.ArrayEach([]byte(ytData), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+	token, err := appendPlaylistItems(&results, contents)
+	if err != nil {
+		return nil, layoutError(err)
+	} else if len(results) == 0 && total > 0 {
+		return nil, ErrLayoutChanged
+	}
+
+	for len(token) > 0 && (limit <= 0 || len(results) < limit) {
+		if onProgress != nil {
+			onProgress(len(results), total)
+		}
+
+		continuation, err := innerTube.BrowseContinuation(token)
+		if err != nil {
+			return nil, err
+		}
+
+		contents, _, _, err = jsonparser.Get(continuation, "onResponseReceivedActions", "[0]", "appendContinuationItemsAction", "continuationItems")
+		if err != nil {
+			return nil, layoutError(err)
+		}
+
+		token, err = appendPlaylistItems(&results, contents)
+		if err != nil {
+			return nil, layoutError(err)
+		}
+	}
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// appendPlaylistItems adds the videos of a page of playlist contents to results, and returns the token
+// of the next page, or an empty string if this was the last page
+func appendPlaylistItems(results *[]MediaItem, contents []byte) (string, error) {
+	token := ""
+	_, err := jsonparser.ArrayEach(contents, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
 		videoRenderer, dataType, _, _ := jsonparser.Get(value, "playlistVideoRenderer")
 		if dataType == jsonparser.Object {
-			results = append(results, VideoRendererToMediaItem(videoRenderer, ""))
+			*results = append(*results, VideoRendererToMediaItem(videoRenderer, ""))
+			return
+		}
+
+		continuationRenderer, dataType, _, _ := jsonparser.Get(value, "continuationItemRenderer")
+		if dataType == jsonparser.Object {
+			token = findContinuationToken(continuationRenderer)
 		}
-	}, "contents", "twoColumnBrowseResultsRenderer", "tabs", "[0]", "tabRenderer", "content", "sectionListRenderer", "contents", "[0]", "itemSectionRenderer", "contents", "[0]", "playlistVideoListRenderer", "contents")
+	})
+	return token, err
+}
+
+func findContinuationToken(continuationRenderer []byte) string {
+	token, err := jsonparser.GetString(continuationRenderer, "continuationEndpoint", "continuationCommand", "token")
+	if err == nil {
+		return token
+	}
+
+	// Newer layouts wrap the continuation command in a list of commands
+	_, _ = jsonparser.ArrayEach(continuationRenderer, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		if commandToken, err := jsonparser.GetString(value, "continuationCommand", "token"); err == nil {
+			token = commandToken
+		}
+	}, "continuationEndpoint", "commandExecutorCommand", "commands")
+	return token
+}
 
-	return results, err
+func findPlaylistLength(data []byte) int {
+	return int(parseCount(findFirstString(data, [][]string{
+		{"header", "playlistHeaderRenderer", "numVideosText", "runs", "[0]", "text"},
+		{"header", "playlistHeaderRenderer", "stats", "[0]", "runs", "[0]", "text"},
+		{"sidebar", "playlistSidebarRenderer", "items", "[0]", "playlistSidebarPrimaryInfoRenderer", "stats", "[0]", "runs", "[0]", "text"},
+	})))
 }
@@ -0,0 +1,108 @@
+package ytapi
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var videoIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+var offsetPattern = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+
+// videoPathPrefixes are the paths that are followed by a video id, like `/shorts/<id>`
+var videoPathPrefixes = []string{"/shorts/", "/live/", "/embed/", "/v/", "/e/"}
+
+// Reference is a parsed link to a YouTube video, a playlist, or a video within a playlist
+type Reference struct {
+	VideoId    string
+	PlaylistId string
+	// Index is the 1-based position of the video within the playlist, or 0 if it is not known
+	Index       int
+	StartOffset time.Duration
+}
+
+// ParseReference parses all common forms of YouTube links, such as `youtu.be/<id>`, `/shorts/<id>`,
+// `/embed/<id>` or `watch?v=<id>&list=<id>&t=1m30s`. It returns false if the link is not a YouTube link,
+// or if it does not point to a video or playlist.
+func ParseReference(link string) (Reference, bool) {
+	urlVal, err := url.Parse(link)
+	if err != nil || !IsYouTubeHost(urlVal.Hostname()) {
+		return Reference{}, false
+	}
+
+	query := urlVal.Query()
+	ref := Reference{
+		PlaylistId: query.Get("list"),
+	}
+
+	if strings.EqualFold(urlVal.Hostname(), "youtu.be") {
+		ref.VideoId = firstPathSegment(urlVal.Path)
+	} else if query.Has("v") {
+		ref.VideoId = query.Get("v")
+	} else {
+		for _, prefix := range videoPathPrefixes {
+			if strings.HasPrefix(urlVal.Path, prefix) {
+				ref.VideoId = firstPathSegment(urlVal.Path[len(prefix)-1:])
+				break
+			}
+		}
+	}
+
+	if !videoIdPattern.MatchString(ref.VideoId) {
+		ref.VideoId = ""
+	}
+	if len(ref.VideoId) == 0 && len(ref.PlaylistId) == 0 {
+		return Reference{}, false
+	}
+
+	ref.Index, _ = strconv.Atoi(query.Get("index"))
+
+	offset := query.Get("t")
+	if len(offset) == 0 {
+		offset = query.Get("start")
+	}
+	if len(offset) == 0 {
+		// Some links carry the timestamp in the fragment, like `#t=90`
+		fragment, _ := url.ParseQuery(urlVal.Fragment)
+		offset = fragment.Get("t")
+	}
+	ref.StartOffset = parseOffset(offset)
+
+	return ref, true
+}
+
+func IsYouTubeHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "youtu.be" || host == "youtube.com" || strings.HasSuffix(host, ".youtube.com") ||
+		host == "youtube-nocookie.com" || strings.HasSuffix(host, ".youtube-nocookie.com")
+}
+
+func firstPathSegment(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if idx := strings.Index(path, "/"); idx >= 0 {
+		path = path[:idx]
+	}
+	return path
+}
+
+// parseOffset reads timestamps like `90`, `90s`, `1m30s`, `1h2m3s` or `1:30`
+func parseOffset(offset string) time.Duration {
+	offset = strings.ToLower(strings.TrimSpace(offset))
+	if len(offset) == 0 {
+		return 0
+	} else if strings.Contains(offset, ":") {
+		return ParseTimestamp(offset)
+	}
+
+	match := offsetPattern.FindStringSubmatch(offset)
+	if match == nil {
+		return 0
+	}
+
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.Atoi(match[3])
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+}
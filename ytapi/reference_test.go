@@ -0,0 +1,104 @@
+package ytapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		link     string
+		expected Reference
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://youtube.com/watch?v=dQw4w9WgXcQ&feature=share", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://youtu.be/dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc&t=42", Reference{VideoId: "dQw4w9WgXcQ", StartOffset: 42 * time.Second}},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ?feature=share", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ?start=90", Reference{VideoId: "dQw4w9WgXcQ", StartOffset: 90 * time.Second}},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://www.youtube.com/v/dQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ"}},
+		{"https://www.youtube.com/playlist?list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG", Reference{PlaylistId: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG"}},
+		{
+			"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG&index=3",
+			Reference{VideoId: "dQw4w9WgXcQ", PlaylistId: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG", Index: 3},
+		},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ&list=RDdQw4w9WgXcQ", Reference{VideoId: "dQw4w9WgXcQ", PlaylistId: "RDdQw4w9WgXcQ"}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=1m30s", Reference{VideoId: "dQw4w9WgXcQ", StartOffset: 90 * time.Second}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ#t=95", Reference{VideoId: "dQw4w9WgXcQ", StartOffset: 95 * time.Second}},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=1m30s&start=10", Reference{VideoId: "dQw4w9WgXcQ", StartOffset: 90 * time.Second}},
+		// A malformed video id is ignored if the link still points to a playlist
+		{"https://www.youtube.com/watch?v=short&list=PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG", Reference{PlaylistId: "PLx0sYbCqOb8TBPRdmBHs5Iftvv9TPboYG"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.link, func(t *testing.T) {
+			ref, ok := ParseReference(test.link)
+			if !ok {
+				t.Fatal("expected the link to be parsed")
+			}
+			if ref != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, ref)
+			}
+		})
+	}
+}
+
+func TestParseReferenceRejectsLinks(t *testing.T) {
+	links := []string{
+		"",
+		"not a link",
+		"https://example.com/watch?v=dQw4w9WgXcQ",
+		"https://notyoutube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtube.com.example.com/watch?v=dQw4w9WgXcQ",
+		"https://vimeo.com/123456",
+		"https://www.youtube.com/",
+		"https://www.youtube.com/@channel",
+		"https://www.youtube.com/watch?v=tooShort",
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ1",
+		"https://www.youtube.com/watch?v=dQw4w9Wg%20cQ",
+		"https://youtu.be/",
+		"https://www.youtube.com/shorts/",
+	}
+
+	for _, link := range links {
+		t.Run(link, func(t *testing.T) {
+			if ref, ok := ParseReference(link); ok {
+				t.Errorf("expected the link to be rejected, got %+v", ref)
+			}
+		})
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		offset   string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"90", 90 * time.Second},
+		{"90s", 90 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"1m", time.Minute},
+		{"2h", 2 * time.Hour},
+		{"1h2m3s", time.Hour + 2*time.Minute + 3*time.Second},
+		{"1H2M3S", time.Hour + 2*time.Minute + 3*time.Second},
+		{" 42 ", 42 * time.Second},
+		{"1:30", 90 * time.Second},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"abc", 0},
+		{"-5", 0},
+		{"1m30x", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.offset, func(t *testing.T) {
+			if offset := parseOffset(test.offset); offset != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, offset)
+			}
+		})
+	}
+}
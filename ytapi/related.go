@@ -0,0 +1,55 @@
+package ytapi
+
+import (
+	"github.com/buger/jsonparser"
+)
+
+// GetRelatedVideos loads the videos that YouTube recommends to watch next after a video
+func GetRelatedVideos(videoId string) ([]MediaItem, error) {
+	data, err := NewInnerTube().Next(videoId, "")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MediaItem, 0)
+	entries := 0
+	_, err = jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		entries++
+		if videoRenderer, dataType, _, _ := jsonparser.Get(value, "compactVideoRenderer"); dataType == jsonparser.Object {
+			results = append(results, VideoRendererToMediaItem(videoRenderer, ""))
+		} else if lockup, dataType, _, _ := jsonparser.Get(value, "lockupViewModel"); dataType == jsonparser.Object {
+			if item, ok := lockupToMediaItem(lockup); ok {
+				results = append(results, item)
+			}
+		}
+	}, "contents", "twoColumnWatchNextResults", "secondaryResults", "secondaryResults", "results")
+	if err != nil {
+		return nil, layoutError(err)
+	} else if len(results) == 0 && entries > 0 {
+		// The list still has entries, but none of them is a known renderer anymore
+		return nil, ErrLayoutChanged
+	}
+
+	return results, nil
+}
+
+// lockupToMediaItem reads the newer `lockupViewModel` layout of recommendations, skipping everything but videos
+func lockupToMediaItem(lockup []byte) (MediaItem, bool) {
+	contentType, _ := jsonparser.GetString(lockup, "contentType")
+	id, _ := jsonparser.GetString(lockup, "contentId")
+	if contentType != "LOCKUP_CONTENT_TYPE_VIDEO" || len(id) == 0 {
+		return MediaItem{}, false
+	}
+
+	metadata, _, _, _ := jsonparser.Get(lockup, "metadata", "lockupMetadataViewModel")
+	name, _ := jsonparser.GetString(metadata, "title", "content")
+	uploader, _ := jsonparser.GetString(metadata, "metadata", "contentMetadataViewModel", "metadataRows", "[0]", "metadataParts", "[0]", "text", "content")
+
+	return MediaItem{
+		Id:       id,
+		Name:     name,
+		Url:      "https://youtube.com/watch?v=" + id,
+		Source:   SourceYouTube,
+		Uploader: uploader,
+	}, true
+}
@@ -2,26 +2,33 @@ package ytapi
 
 import (
 	"github.com/buger/jsonparser"
-	"net/url"
+	"strconv"
 )
 
 func Search(query string) ([]MediaItem, error) {
-	reqUrl := "https://www.youtube.com/results?search_query=" + url.QueryEscape(query)
-	body, err := Get(reqUrl)
+	data, err := NewInnerTube().Search(query)
 	if err != nil {
 		return nil, err
 	}
 
-	ytData := FindYouTubeData(body)
-
 	results := make([]MediaItem, 0)
+	_, err = jsonparser.ArrayEach(data, func(section []byte, dataType jsonparser.ValueType, offset int, err error) {
+		_, _ = jsonparser.ArrayEach(section, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			videoRenderer, dataType, _, _ := jsonparser.Get(value, "videoRenderer")
+			if dataType == jsonparser.Object {
+				results = append(results, VideoRendererToMediaItem(videoRenderer, ""))
+			}
+		}, "itemSectionRenderer", "contents")
+	}, "contents", "twoColumnSearchResultsRenderer", "primaryContents", "sectionListRenderer", "contents")
+	if err != nil {
+		return nil, layoutError(err)
+	}
 
-	_, err = jsonparser.ArrayEach([]byte(ytData), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-		videoRenderer, dataType, _, _ := jsonparser.Get(value, "videoRenderer")
-		if dataType == jsonparser.Object {
-			results = append(results, VideoRendererToMediaItem(videoRenderer, ""))
-		}
-	}, "contents", "twoColumnSearchResultsRenderer", "primaryContents", "sectionListRenderer", "contents", "[0]", "itemSectionRenderer", "contents")
+	// YouTube reports an estimate of the results, so zero parsed videos for a non-empty search mean that the renderers changed
+	estimatedResults, _ := jsonparser.GetString(data, "estimatedResults")
+	if count, _ := strconv.ParseInt(estimatedResults, 10, 64); len(results) == 0 && count > 0 {
+		return nil, ErrLayoutChanged
+	}
 
-	return results, err
+	return results, nil
 }
@@ -1,24 +1,53 @@
 package ytapi
 
 import (
+	"errors"
 	"github.com/buger/jsonparser"
-	"net/url"
 )
 
 func GetVideo(id string) (MediaItem, error) {
-	reqUrl := "https://www.youtube.com/watch?v=" + url.QueryEscape(id)
-	body, err := Get(reqUrl)
+	data, err := NewInnerTube().Player(id)
 	if err != nil {
 		return MediaItem{}, err
 	}
 
-	ytData := FindYouTubeData(body)
-
-	renderer, _, _, err := jsonparser.Get([]byte(ytData), "contents", "twoColumnWatchNextResults", "results", "results", "contents", "[0]", "videoPrimaryInfoRenderer")
+	videoDetails, _, _, err := jsonparser.Get(data, "videoDetails")
 	if err != nil {
-		return MediaItem{}, err
+		reason, _ := jsonparser.GetString(data, "playabilityStatus", "reason")
+		if len(reason) > 0 {
+			return MediaItem{}, errors.New(reason)
+		}
+		return MediaItem{}, layoutError(err)
 	}
-	item := VideoRendererToMediaItem(renderer, id)
 
+	item := VideoDetailsToMediaItem(videoDetails, id)
+	if len(item.Name) == 0 {
+		return MediaItem{}, ErrLayoutChanged
+	}
 	return item, nil
 }
+
+// VideoDetailsToMediaItem reads the `videoDetails` of a player response
+func VideoDetailsToMediaItem(videoDetails []byte, fallbackId string) MediaItem {
+	id, _ := jsonparser.GetString(videoDetails, "videoId")
+	name, _ := jsonparser.GetString(videoDetails, "title")
+	author, _ := jsonparser.GetString(videoDetails, "author")
+	viewCount, _ := jsonparser.GetString(videoDetails, "viewCount")
+	isLive, _ := jsonparser.GetBoolean(videoDetails, "isLive")
+
+	if len(id) == 0 {
+		id = fallbackId
+	}
+
+	return MediaItem{
+		Id:        id,
+		Name:      name,
+		Url:       "https://youtube.com/watch?v=" + id,
+		Source:    SourceYouTube,
+		Duration:  findDuration(videoDetails),
+		Uploader:  author,
+		Thumbnail: findThumbnail(videoDetails),
+		ViewCount: parseCount(viewCount),
+		IsLive:    isLive,
+	}
+}
@@ -0,0 +1,148 @@
+package ytdlp
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	expirySafetyMargin   = 5 * time.Minute
+	defaultCacheLifetime = 30 * time.Minute
+)
+
+// StreamInfo is a resolved stream URL together with its format and the time it expires.
+// IsLive is set if yt-dlp reports the video as a live stream that is still running.
+type StreamInfo struct {
+	Url     string
+	Format  string
+	Expires time.Time
+	IsLive  bool
+}
+
+// CacheStats contains the counters of the stream URL cache
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type streamCall struct {
+	done chan interface{}
+	info StreamInfo
+	err  error
+}
+
+var (
+	cacheMutex    sync.Mutex
+	cacheEntries  = make(map[string]StreamInfo)
+	cacheInflight = make(map[string]*streamCall)
+	cacheHits     uint64
+	cacheMisses   uint64
+)
+
+// GetStream resolves the stream of a video, using the cache if the URL was resolved before and did not expire yet.
+// Concurrent calls for the same video only invoke yt-dlp once. The shared invocation is not bound to the
+// context of any caller, so that a cancelled caller does not fail the others.
+func GetStream(ctx context.Context, videoUrl string) (StreamInfo, error) {
+	key := cacheKey(videoUrl)
+
+	cacheMutex.Lock()
+	if info, ok := cacheEntries[key]; ok {
+		if time.Now().Before(info.Expires) {
+			cacheMutex.Unlock()
+			atomic.AddUint64(&cacheHits, 1)
+			zap.S().Debugw("Stream URL cache hit", "key", key)
+			return info, nil
+		}
+		delete(cacheEntries, key)
+	}
+	atomic.AddUint64(&cacheMisses, 1)
+
+	call, inflight := cacheInflight[key]
+	if !inflight {
+		call = &streamCall{done: make(chan interface{})}
+		cacheInflight[key] = call
+		go resolveAndCache(key, videoUrl, call)
+	} else {
+		zap.S().Debugw("Waiting for concurrent stream URL resolution", "key", key)
+	}
+	cacheMutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.info, call.err
+	case <-ctx.Done():
+		return StreamInfo{}, contextError(ctx)
+	}
+}
+
+func resolveAndCache(key string, videoUrl string, call *streamCall) {
+	call.info, call.err = resolveStream(context.Background(), videoUrl)
+
+	cacheMutex.Lock()
+	delete(cacheInflight, key)
+	if call.err == nil {
+		evictExpired()
+		cacheEntries[key] = call.info
+	}
+	cacheMutex.Unlock()
+	close(call.done)
+}
+
+// InvalidateStream removes a video from the cache, e.g. because its URL was rejected by the server
+func InvalidateStream(videoUrl string) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	delete(cacheEntries, cacheKey(videoUrl))
+}
+
+func GetCacheStats() CacheStats {
+	cacheMutex.Lock()
+	entries := len(cacheEntries)
+	cacheMutex.Unlock()
+
+	return CacheStats{
+		Hits:    atomic.LoadUint64(&cacheHits),
+		Misses:  atomic.LoadUint64(&cacheMisses),
+		Entries: entries,
+	}
+}
+
+func evictExpired() {
+	now := time.Now()
+	for key, info := range cacheEntries {
+		if now.After(info.Expires) {
+			delete(cacheEntries, key)
+		}
+	}
+}
+
+// cacheKey returns the video id of YouTube URLs, and the URL itself for everything else
+func cacheKey(videoUrl string) string {
+	urlVal, err := url.Parse(videoUrl)
+	if err != nil {
+		return videoUrl
+	}
+
+	if id := urlVal.Query().Get("v"); len(id) > 0 {
+		return id
+	} else if strings.EqualFold(urlVal.Hostname(), "youtu.be") && len(urlVal.Path) > 1 {
+		return urlVal.Path[1:]
+	}
+	return videoUrl
+}
+
+// streamExpiry reads the `expire` parameter of a stream URL, and subtracts a safety margin from it
+func streamExpiry(streamUrl *url.URL) time.Time {
+	expire, err := strconv.ParseInt(streamUrl.Query().Get("expire"), 10, 64)
+	if err != nil {
+		return time.Now().Add(defaultCacheLifetime)
+	}
+	return time.Unix(expire, 0).Add(-expirySafetyMargin)
+}
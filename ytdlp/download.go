@@ -1,65 +1,275 @@
 package ytdlp
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"ytbot/config"
 )
 
-func EnsurePresent() error {
+const installTimeout = 5 * time.Minute
+
+// httpClient gives up on downloads of release files that take too long, even if the context has no deadline
+var httpClient = &http.Client{Timeout: installTimeout}
+
+var ErrChecksumMismatch = errors.New("the checksum of the downloaded yt-dlp does not match the release")
+
+// EnsurePresent installs yt-dlp if it is missing, or if it does not match the pinned version.
+// If the context has no deadline, the installation is given up after installTimeout.
+func EnsurePresent(ctx context.Context) error {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, installTimeout)
+		defer cancel()
+	}
+
 	zap.S().Debugln("Checking if yt-dlp is present")
 
-	downloadPath := getExecutablePath()
-	zap.S().Debugw("Path for yt-dlp was loaded", "path", downloadPath)
+	path := getExecutablePath()
+	zap.S().Debugw("Path for yt-dlp was loaded", "path", path)
 
-	_, err := os.Stat(downloadPath)
+	_, err := os.Stat(path)
 	if errors.Is(err, os.ErrNotExist) {
 		zap.S().Debugln("yt-dlp is not present, downloading")
-		err = download(downloadPath)
+		err = install(ctx)
 		if err != nil {
 			return errors.New("Failed to download yt-dlp: " + err.Error())
 		}
-	} else {
-		zap.S().Debugln("yt-dlp is present")
+	} else if err != nil {
+		return err
 	}
 
 	zap.S().Debugln("Validating yt-dlp executable")
-	validate()
+	version, err := GetVersion(ctx)
+	if err != nil {
+		return errors.New("Failed to get version from yt-dlp. Executable may be corrupted: " + err.Error())
+	}
+	zap.S().Infow("Found valid yt-dlp executable", "version", version)
+
+	if pinned := getPinnedVersion(); len(pinned) > 0 && pinned != version {
+		zap.S().Infow("Installed yt-dlp does not match the pinned version", "version", version, "pinnedVersion", pinned)
+		return install(ctx)
+	}
 
 	return nil
 }
 
-func download(path string) error {
-	downloadUrl := getExecutableUrl()
-	zap.S().Infow("Downloading yt-dlp executable", "downloadUrl", downloadUrl)
+// CheckForUpdates installs a new yt-dlp if the pinned version changed, or if the latest
+// release differs from the installed executable
+func CheckForUpdates(ctx context.Context) error {
+	prevVer, err := GetVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pinned := getPinnedVersion(); len(pinned) > 0 {
+		if pinned == prevVer {
+			zap.S().Infow("yt-dlp is at the pinned version", "version", prevVer)
+			return nil
+		}
+	} else {
+		expectedHash, err := fetchChecksum(ctx)
+		if err != nil {
+			return err
+		}
+
+		localHash, err := hashFile(getExecutablePath())
+		if err != nil {
+			return err
+		}
+
+		if localHash == expectedHash {
+			zap.S().Infow("yt-dlp is at the latest version", "version", prevVer)
+			return nil
+		}
+	}
 
-	out, err := os.Create(path)
+	err = install(ctx)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
-	resp, err := http.Get(downloadUrl)
+	curVer, err := GetVersion(ctx)
+	if err != nil {
+		return err
+	}
+	zap.S().Infow("yt-dlp was updated to a new version", "curVer", curVer, "prevVer", prevVer)
+	return nil
+}
+
+// StartUpdateChecker checks for yt-dlp updates in the background, once immediately and then at the configured interval
+func StartUpdateChecker() {
+	interval := config.GetMilliseconds(config.KeyYtdlpUpdateInterval)
+	if interval <= 0 {
+		zap.S().Infoln("Automatic yt-dlp updates are disabled")
+		return
+	}
+
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), installTimeout)
+			err := CheckForUpdates(ctx)
+			cancel()
+			if err != nil {
+				zap.S().Warnw("Failed to check for yt-dlp updates", "error", err)
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// install downloads and verifies yt-dlp next to the current executable, and then atomically replaces
+// it. If the new executable fails to report its version, the previous one is restored.
+func install(ctx context.Context) error {
+	expectedHash, err := fetchChecksum(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := getExecutablePath()
+	err = os.MkdirAll(filepath.Dir(path), 0755)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	tempPath, err := download(ctx, filepath.Dir(path), expectedHash)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tempPath)
+
+	installMutex.Lock()
+	defer installMutex.Unlock()
+
+	backupPath := path + ".old"
+	_, err = os.Stat(path)
+	hasBackup := err == nil
+	if hasBackup {
+		err = os.Rename(path, backupPath)
+		if err != nil {
+			return err
+		}
+	}
 
+	err = os.Rename(tempPath, path)
+	if err == nil {
+		_, err = runExecutable(ctx, path, "--version")
+	}
+
+	if err != nil {
+		zap.S().Warnw("New yt-dlp executable is not working, rolling back", "error", err)
+		if hasBackup {
+			if rollbackErr := os.Rename(backupPath, path); rollbackErr != nil {
+				zap.S().Errorw("Failed to restore previous yt-dlp executable", "error", rollbackErr)
+			}
+		}
+		return err
+	}
+
+	if hasBackup {
+		_ = os.Remove(backupPath)
+	}
 	return nil
 }
 
-func validate() {
-	ver, err := GetVersion()
+// download saves the yt-dlp executable into a temporary file in dir and verifies its checksum
+func download(ctx context.Context, dir string, expectedHash string) (string, error) {
+	downloadUrl := getReleaseUrl(getExecutableFileName())
+	zap.S().Infow("Downloading yt-dlp executable", "downloadUrl", downloadUrl)
+
+	resp, err := httpGet(ctx, downloadUrl)
 	if err != nil {
-		zap.S().Fatalw("Failed to get version from yt-dlp. Executable may be corrupted.", "error", err)
-	} else {
-		zap.S().Infow("Found valid yt-dlp executable", "version", ver)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	out, err := os.CreateTemp(dir, ".yt-dlp-*")
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, hash), resp.Body)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(hash.Sum(nil)) != expectedHash {
+		err = ErrChecksumMismatch
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), 0755)
+	}
+
+	if err != nil {
+		_ = os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// fetchChecksum reads the SHA-256 checksum of the executable from the checksum file of the release
+func fetchChecksum(ctx context.Context) (string, error) {
+	resp, err := httpGet(ctx, getReleaseUrl(checksumFileName))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	fileName := getExecutableFileName()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == fileName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("the release does not contain a checksum for " + fileName)
+}
+
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status %d for %s", resp.StatusCode, url)
+	}
+	return resp, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
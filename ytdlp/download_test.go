@@ -0,0 +1,163 @@
+package ytdlp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"ytbot/config"
+)
+
+const (
+	workingExecutable = "#!/bin/sh\necho 2024.01.01\n"
+	brokenExecutable  = "#!/bin/sh\nexit 1\n"
+	installedVersion  = "#!/bin/sh\necho 2023.12.30\n"
+)
+
+// startReleaseServer serves a yt-dlp release with the given executable and checksum file, and configures
+// the package to install yt-dlp from it into a temporary directory
+func startReleaseServer(t *testing.T, executable string, checksum string) string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("the test executables are shell scripts")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/download/" + checksumFileName:
+			_, _ = w.Write([]byte(checksum + "  yt-dlp.exe\n" + checksum + "  " + linuxFileName + "\n"))
+		case "/latest/download/" + linuxFileName:
+			_, _ = w.Write([]byte(executable))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	config.Set(config.KeyYtdlpDownloadUrl, server.URL)
+	config.Set(config.KeyYtdlpDirectory, dir)
+	config.Set(config.KeyYtdlpVersion, latestVersion)
+	return dir
+}
+
+func sha256Hex(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+func writeExecutable(t *testing.T, dir string, content string) {
+	t.Helper()
+	err := os.WriteFile(filepath.Join(dir, linuxFileName), []byte(content), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertInstalled checks that the executable in dir has the given content and that no temporary files are left
+func assertInstalled(t *testing.T, dir string, content string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, linuxFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("expected the installed executable to be %q, got %q", content, data)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("expected only the executable to be left, found %s", strings.Join(names, ", "))
+	}
+}
+
+func TestInstallReplacesExecutable(t *testing.T) {
+	dir := startReleaseServer(t, workingExecutable, sha256Hex(workingExecutable))
+	writeExecutable(t, dir, installedVersion)
+
+	err := install(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInstalled(t, dir, workingExecutable)
+
+	version, err := GetVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "2024.01.01" {
+		t.Errorf("expected version 2024.01.01, got %s", version)
+	}
+}
+
+func TestInstallRejectsChecksumMismatch(t *testing.T) {
+	dir := startReleaseServer(t, workingExecutable, sha256Hex("something else"))
+	writeExecutable(t, dir, installedVersion)
+
+	err := install(context.Background())
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	assertInstalled(t, dir, installedVersion)
+}
+
+func TestInstallRollsBackBrokenExecutable(t *testing.T) {
+	dir := startReleaseServer(t, brokenExecutable, sha256Hex(brokenExecutable))
+	writeExecutable(t, dir, installedVersion)
+
+	err := install(context.Background())
+	if err == nil {
+		t.Fatal("expected the broken executable to be rejected")
+	}
+	assertInstalled(t, dir, installedVersion)
+}
+
+func TestInstallFailsForMissingRelease(t *testing.T) {
+	dir := startReleaseServer(t, workingExecutable, sha256Hex(workingExecutable))
+	writeExecutable(t, dir, installedVersion)
+	config.Set(config.KeyYtdlpVersion, "2000.01.01")
+
+	err := install(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	assertInstalled(t, dir, installedVersion)
+}
+
+func TestInstallGivesUpOnStalledDownload(t *testing.T) {
+	dir := startReleaseServer(t, workingExecutable, sha256Hex(workingExecutable))
+	writeExecutable(t, dir, installedVersion)
+
+	stalled := make(chan interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-stalled
+	}))
+	defer server.Close()
+	defer close(stalled)
+	config.Set(config.KeyYtdlpDownloadUrl, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := install(ctx)
+	if err == nil {
+		t.Fatal("expected the stalled download to fail")
+	}
+	assertInstalled(t, dir, installedVersion)
+}
@@ -0,0 +1,68 @@
+package ytdlp
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrPrivateVideo    = errors.New("the video is private")
+	ErrAgeRestricted   = errors.New("the video is age restricted")
+	ErrGeoBlocked      = errors.New("the video is not available in this country")
+	ErrVideoRemoved    = errors.New("the video was removed or is unavailable")
+	ErrSignInRequired  = errors.New("YouTube requires signing in to access the video")
+	ErrTimeout         = errors.New("yt-dlp did not respond in time")
+	ErrExtractorFailed = errors.New("yt-dlp failed to extract the media")
+)
+
+// YtdlpError describes a failed yt-dlp invocation. Kind is one of the Err* values
+// of this package and can be checked using errors.Is
+type YtdlpError struct {
+	Kind    error
+	Message string
+}
+
+func (err *YtdlpError) Error() string {
+	return err.Kind.Error() + ": " + err.Message
+}
+
+func (err *YtdlpError) Unwrap() error {
+	return err.Kind
+}
+
+var errorPatterns = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrAgeRestricted, []string{"confirm your age", "age-restricted", "age restricted", "inappropriate for some users"}},
+	{ErrPrivateVideo, []string{"private video", "this video is private"}},
+	{ErrGeoBlocked, []string{"not available in your country", "geo restriction", "geo-restricted", "blocked it in your country", "not made this video available in your country"}},
+	{ErrVideoRemoved, []string{"has been removed", "has been terminated", "no longer available", "video unavailable", "does not exist"}},
+	{ErrSignInRequired, []string{"sign in to confirm", "login required", "members-only", "join this channel", "use --cookies"}},
+}
+
+// parseError classifies the output of a failed yt-dlp invocation
+func parseError(stderr string) *YtdlpError {
+	message := lastErrorLine(stderr)
+	lowerStderr := strings.ToLower(stderr)
+
+	for _, candidate := range errorPatterns {
+		for _, pattern := range candidate.patterns {
+			if strings.Contains(lowerStderr, pattern) {
+				return &YtdlpError{Kind: candidate.kind, Message: message}
+			}
+		}
+	}
+
+	return &YtdlpError{Kind: ErrExtractorFailed, Message: message}
+}
+
+func lastErrorLine(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "ERROR:") {
+			return strings.TrimSpace(strings.TrimPrefix(lines[i], "ERROR:"))
+		}
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,94 @@
+package ytdlp
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"ytbot/ytapi"
+)
+
+// extractorResult is the information that yt-dlp reports about a URL when called with `--flat-playlist -J`.
+// Single media are reported with their full Metadata, while playlists, sets and albums only list their entries.
+type extractorResult struct {
+	Metadata
+	Type    string          `json:"_type"`
+	Entries []playlistEntry `json:"entries"`
+}
+
+type playlistEntry struct {
+	Id         string  `json:"id"`
+	Title      string  `json:"title"`
+	Url        string  `json:"url"`
+	WebpageUrl string  `json:"webpage_url"`
+	Duration   float64 `json:"duration"`
+	Uploader   string  `json:"uploader"`
+	Channel    string  `json:"channel"`
+	Extractor  string  `json:"ie_key"`
+}
+
+// LoadMediaItems loads the media of any URL that one of the yt-dlp extractors supports
+func LoadMediaItems(ctx context.Context, mediaUrl string) ([]ytapi.MediaItem, error) {
+	output, err := runYtdl(ctx, "--flat-playlist", "-J", mediaUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var result extractorResult
+	err = json.Unmarshal([]byte(output), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Type != "playlist" && result.Type != "multi_video" {
+		item := ytapi.MediaItem{Url: result.WebpageUrl}
+		if len(item.Url) == 0 {
+			item.Url = mediaUrl
+		}
+		applyMetadata(&item, result.Metadata)
+		item.Type = mediaType(item.Source)
+		return []ytapi.MediaItem{item}, nil
+	}
+
+	items := make([]ytapi.MediaItem, 0)
+	for _, entry := range result.Entries {
+		entryUrl := entry.WebpageUrl
+		if len(entryUrl) == 0 {
+			entryUrl = entry.Url
+		}
+		if urlVal, err := url.Parse(entryUrl); err != nil || len(urlVal.Hostname()) == 0 {
+			// Some extractors only list the ids of the entries, which yt-dlp can not play on their own
+			continue
+		}
+
+		uploader := entry.Uploader
+		if len(uploader) == 0 {
+			uploader = entry.Channel
+		}
+		source := entry.Extractor
+		if len(source) == 0 {
+			source = result.Extractor
+		}
+		name := entry.Title
+		if len(name) == 0 {
+			name = entryUrl
+		}
+
+		items = append(items, ytapi.MediaItem{
+			Id:       entry.Id,
+			Name:     name,
+			Url:      entryUrl,
+			Type:     mediaType(source),
+			Source:   source,
+			Duration: secondsToDuration(entry.Duration),
+			Uploader: uploader,
+		})
+	}
+	return items, nil
+}
+
+func mediaType(source string) ytapi.MediaType {
+	if source == ytapi.SourceYouTube {
+		return ytapi.MediaTypeYouTube
+	}
+	return ytapi.MediaTypeExtractor
+}
@@ -1,81 +1,62 @@
 package ytdlp
 
 import (
+	"context"
 	"errors"
 	"go.uber.org/zap"
 	"net/url"
-	"os/exec"
 	"strings"
 )
 
-func CheckForUpdates() error {
-	prevVer, err := GetVersion()
-	if err != nil {
-		return err
-	}
-
-	_, err = runYtdl("-U")
-	if err != nil {
-		return err
-	}
-
-	curVer, err := GetVersion()
-	if err != nil {
-		return err
-	}
-
-	if curVer == prevVer {
-		zap.S().Infow("yt-dlp is at the latest version", "version", curVer)
-	} else {
-		zap.S().Infow("yt-dlp was updated to a new version", "curVer", curVer, "prevVer", prevVer)
-	}
-
-	return nil
+func GetVersion(ctx context.Context) (string, error) {
+	ver, err := runYtdl(ctx, "--version")
+	return strings.TrimSpace(ver), err
 }
 
-func GetVersion() (string, error) {
-	ver, err := runYtdl("--version")
-	return strings.TrimSpace(ver), err
+// RefreshStream resolves the stream of a video without using the cache
+func RefreshStream(ctx context.Context, ytUrl string) (StreamInfo, error) {
+	InvalidateStream(ytUrl)
+	return GetStream(ctx, ytUrl)
 }
 
-func GetStreamUrl(ytUrl string) (string, error) {
-	result, err := runYtdl("-g", ytUrl)
+func resolveStream(ctx context.Context, ytUrl string) (StreamInfo, error) {
+	// The live status is printed in the first line, followed by the URLs of the format
+	result, err := runYtdl(ctx, "-f", "bestaudio/best", "--print", "live_status", "--print", "urls", ytUrl)
 	if err != nil {
-		return "", err
+		return StreamInfo{}, err
 	}
 
-	urls := strings.Split(result, "\n")
+	lines := strings.Split(strings.TrimSpace(result), "\n")
+	isLive := strings.TrimSpace(lines[0]) == liveStatusLive
 	validUrls := make([]*url.URL, 0)
-	for _, urlStr := range urls {
-		urlObj, err := url.Parse(urlStr)
-		if err == nil {
+	for _, urlStr := range lines[1:] {
+		urlObj, err := url.Parse(strings.TrimSpace(urlStr))
+		if err == nil && len(urlObj.Host) > 0 {
 			validUrls = append(validUrls, urlObj)
 		}
 	}
 
 	if len(validUrls) == 0 {
-		return "", errors.New("could not resolve YouTube video")
+		return StreamInfo{}, errors.New("could not resolve media URL")
 	} else if len(validUrls) == 1 {
-		return validUrls[0].String(), nil
+		return newStreamInfo(validUrls[0], isLive), nil
 	} else {
 		for _, candidate := range validUrls {
 			if strings.HasPrefix(candidate.Query().Get("mime"), "audio") {
-				return candidate.String(), nil
+				return newStreamInfo(candidate, isLive), nil
 			}
 		}
 
 		zap.S().Warnw("No download URL with audio mimetype was found, returning best effort.", "urlCandidates", validUrls)
-		return validUrls[0].String(), nil
+		return newStreamInfo(validUrls[0], isLive), nil
 	}
 }
 
-func runYtdl(args ...string) (string, error) {
-	cmd := exec.Command(getExecutablePath(), args...)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
+func newStreamInfo(streamUrl *url.URL, isLive bool) StreamInfo {
+	return StreamInfo{
+		Url:     streamUrl.String(),
+		Format:  streamUrl.Query().Get("mime"),
+		Expires: streamExpiry(streamUrl),
+		IsLive:  isLive,
 	}
-
-	return string(output), nil
 }
@@ -0,0 +1,31 @@
+package ytdlp
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"ytbot/config"
+)
+
+// streamExecutable prints the live status given as the URL, followed by a stream URL, like `--print live_status --print urls`
+const streamExecutable = "#!/bin/sh\nfor arg; do status=$arg; done\necho $status\necho https://example.com/audio.m3u8\n"
+
+func TestResolveStreamReadsLiveStatus(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the test executables are shell scripts")
+	}
+	dir := t.TempDir()
+	writeExecutable(t, dir, streamExecutable)
+	config.Set(config.KeyYtdlpDirectory, dir)
+
+	// Finite HLS streams, e.g. of SoundCloud, must not be mistaken for live streams
+	for status, expected := range map[string]bool{"is_live": true, "was_live": false, "not_live": false, "NA": false} {
+		info, err := resolveStream(context.Background(), status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Url != "https://example.com/audio.m3u8" || info.IsLive != expected {
+			t.Errorf("expected %s to resolve to a stream with IsLive %t, got %+v", status, expected, info)
+		}
+	}
+}
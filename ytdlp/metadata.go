@@ -0,0 +1,146 @@
+package ytdlp
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"ytbot/ytapi"
+)
+
+// liveStatusLive is the `live_status` of live streams that are still running. Finished live streams are
+// reported as `was_live` or `post_live`, and play like any other video.
+const liveStatusLive = "is_live"
+
+// Metadata is the information that yt-dlp reports about a video when called with `-J`
+type Metadata struct {
+	Id         string    `json:"id"`
+	Title      string    `json:"title"`
+	Duration   float64   `json:"duration"`
+	Uploader   string    `json:"uploader"`
+	Channel    string    `json:"channel"`
+	Thumbnail  string    `json:"thumbnail"`
+	ViewCount  int64     `json:"view_count"`
+	IsLive     bool      `json:"is_live"`
+	LiveStatus string    `json:"live_status"`
+	WebpageUrl string    `json:"webpage_url"`
+	Extractor  string    `json:"extractor_key"`
+	Chapters   []Chapter `json:"chapters"`
+	Formats    []Format  `json:"formats"`
+}
+
+type Chapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+type Format struct {
+	FormatId   string  `json:"format_id"`
+	Extension  string  `json:"ext"`
+	AudioCodec string  `json:"acodec"`
+	VideoCodec string  `json:"vcodec"`
+	AudioRate  float64 `json:"abr"`
+	Url        string  `json:"url"`
+}
+
+func GetMetadata(ctx context.Context, url string) (Metadata, error) {
+	output, err := runYtdl(ctx, "-J", "--no-playlist", url)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var metadata Metadata
+	err = json.Unmarshal([]byte(output), &metadata)
+	return metadata, err
+}
+
+// AudioFormats returns all formats that only contain audio
+func (metadata *Metadata) AudioFormats() []Format {
+	formats := make([]Format, 0)
+	for _, format := range metadata.Formats {
+		if format.VideoCodec == "none" && format.AudioCodec != "none" && len(format.AudioCodec) > 0 {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// MediaChapters converts the chapters that yt-dlp found in the metadata or the description
+func (metadata *Metadata) MediaChapters() []ytapi.Chapter {
+	chapters := make([]ytapi.Chapter, 0, len(metadata.Chapters))
+	for _, chapter := range metadata.Chapters {
+		chapters = append(chapters, ytapi.Chapter{
+			Title: chapter.Title,
+			Start: secondsToDuration(chapter.StartTime),
+			End:   secondsToDuration(chapter.EndTime),
+		})
+	}
+	return chapters
+}
+
+// GetChapters loads the chapters of a video
+func GetChapters(ctx context.Context, url string) ([]ytapi.Chapter, error) {
+	metadata, err := GetMetadata(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.MediaChapters(), nil
+}
+
+// FillMetadata loads the metadata of a media item using yt-dlp, and fills in all fields that are still empty
+func FillMetadata(ctx context.Context, item *ytapi.MediaItem) error {
+	metadata, err := GetMetadata(ctx, item.Url)
+	if err != nil {
+		return err
+	}
+
+	applyMetadata(item, metadata)
+	return nil
+}
+
+// applyMetadata fills in all fields of the media item that are still empty
+func applyMetadata(item *ytapi.MediaItem, metadata Metadata) {
+	if len(item.Id) == 0 {
+		item.Id = metadata.Id
+	}
+	if len(item.Name) == 0 {
+		item.Name = metadata.Title
+	}
+	if item.Duration == 0 {
+		item.Duration = secondsToDuration(metadata.Duration)
+	}
+	if len(item.Uploader) == 0 {
+		item.Uploader = metadata.Uploader
+		if len(item.Uploader) == 0 {
+			item.Uploader = metadata.Channel
+		}
+	}
+	if len(item.Thumbnail) == 0 {
+		item.Thumbnail = metadata.Thumbnail
+	}
+	if item.ViewCount == 0 {
+		item.ViewCount = metadata.ViewCount
+	}
+	if len(item.Source) == 0 {
+		item.Source = metadata.Extractor
+	}
+	item.IsLive = item.IsLive || metadata.IsLive || metadata.LiveStatus == liveStatusLive
+
+	if len(item.Chapters) == 0 {
+		item.Chapters = metadata.MediaChapters()
+	}
+
+	if len(item.AudioFormats) == 0 {
+		for _, format := range metadata.AudioFormats() {
+			item.AudioFormats = append(item.AudioFormats, ytapi.AudioFormat{
+				Id:      format.FormatId,
+				Codec:   format.AudioCodec,
+				Bitrate: format.AudioRate,
+			})
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
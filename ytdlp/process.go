@@ -0,0 +1,111 @@
+package ytdlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"os/exec"
+	"sync"
+	"ytbot/config"
+)
+
+var processSlots chan interface{}
+var processSlotsOnce sync.Once
+
+// installMutex prevents yt-dlp from being started while its executable is being replaced
+var installMutex sync.RWMutex
+
+// runYtdl runs yt-dlp with the given arguments and returns its output. If the context has no deadline,
+// the configured default timeout is applied. At most the configured number of yt-dlp processes run
+// at the same time, other calls wait for a free slot.
+func runYtdl(ctx context.Context, args ...string) (string, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.GetMilliseconds(config.KeyYtdlpTimeout))
+		defer cancel()
+	}
+
+	err := acquireProcessSlot(ctx)
+	if err != nil {
+		return "", contextError(ctx)
+	}
+	defer releaseProcessSlot()
+
+	installMutex.RLock()
+	defer installMutex.RUnlock()
+
+	return runExecutable(ctx, getExecutablePath(), args...)
+}
+
+// runExecutable runs the yt-dlp executable at path until it exits or the context ends
+func runExecutable(ctx context.Context, path string, args ...string) (string, error) {
+	cmd := exec.Command(path, args...)
+	setProcessGroup(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Start()
+	if err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		zap.S().Warnw("Killing yt-dlp because the context ended", "args", args, "error", ctx.Err())
+		if killErr := killProcessGroup(cmd); killErr != nil {
+			zap.S().Warnw("Failed to kill yt-dlp process group", "error", killErr)
+		}
+		<-done
+		return "", contextError(ctx)
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			ytdlpErr := parseError(stderr.String())
+			zap.S().Debugw("yt-dlp exited with an error", "args", args, "kind", ytdlpErr.Kind, "message", ytdlpErr.Message)
+			return "", ytdlpErr
+		}
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
+
+func acquireProcessSlot(ctx context.Context) error {
+	processSlotsOnce.Do(func() {
+		maxProcesses := config.GetInt(config.KeyYtdlpMaxProcesses)
+		if maxProcesses < 1 {
+			zap.S().Warnw("Invalid maximum number of yt-dlp processes, allowing one process", "key", config.KeyYtdlpMaxProcesses, "value", maxProcesses)
+			maxProcesses = 1
+		}
+		processSlots = make(chan interface{}, maxProcesses)
+	})
+
+	select {
+	case processSlots <- nil:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func releaseProcessSlot() {
+	<-processSlots
+}
+
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
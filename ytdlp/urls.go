@@ -2,12 +2,14 @@ package ytdlp
 
 import (
 	"log"
-	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"ytbot/config"
 )
 
-const baseUrl = "https://github.com/yt-dlp/yt-dlp/releases/latest/download/"
+const latestVersion = "latest"
+const checksumFileName = "SHA2-256SUMS"
 const windowsFileName = "yt-dlp.exe"
 const linuxFileName = "yt-dlp"
 
@@ -23,14 +25,27 @@ func getExecutableFileName() string {
 	return ""
 }
 
-func getExecutableUrl() string {
-	return baseUrl + getExecutableFileName()
+// getPinnedVersion returns the configured yt-dlp version, or an empty string if the latest version should be used
+func getPinnedVersion() string {
+	version := strings.TrimSpace(config.GetString(config.KeyYtdlpVersion))
+	if strings.EqualFold(version, latestVersion) {
+		return ""
+	}
+	return version
+}
+
+func getReleaseUrl(fileName string) string {
+	baseUrl := strings.TrimSuffix(config.GetString(config.KeyYtdlpDownloadUrl), "/")
+	if version := getPinnedVersion(); len(version) > 0 {
+		return baseUrl + "/download/" + version + "/" + fileName
+	}
+	return baseUrl + "/latest/download/" + fileName
 }
 
 func getExecutablePath() string {
-	dir, err := os.Getwd()
+	dir, err := filepath.Abs(config.GetString(config.KeyYtdlpDirectory))
 	if err != nil {
-		log.Fatalln("Failed to get current working directory:", err)
+		log.Fatalln("Failed to resolve yt-dlp installation directory:", err)
 	}
 
 	return filepath.Join(dir, getExecutableFileName())